@@ -0,0 +1,267 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/playground/internal"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/deepequalerrors"
+	"golang.org/x/tools/go/analysis/passes/fieldalignment"
+	"golang.org/x/tools/go/analysis/passes/nilness"
+	"golang.org/x/tools/go/analysis/passes/reflectvaluecompare"
+	"golang.org/x/tools/go/analysis/passes/shadow"
+	"golang.org/x/tools/go/analysis/passes/sortslice"
+	"golang.org/x/tools/go/analysis/passes/unusedwrite"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+// analyzers are the analyzers beyond go vet's that requests may
+// select, by name. They are compiled into the server, which go vet runs
+// as its -vettool; see runVettool.
+var analyzers = map[string]*analysis.Analyzer{
+	"deepequalerrors":     deepequalerrors.Analyzer,
+	"fieldalignment":      fieldalignment.Analyzer,
+	"nilness":             nilness.Analyzer,
+	"reflectvaluecompare": reflectvaluecompare.Analyzer,
+	"shadow":              shadow.Analyzer,
+	"sortslice":           sortslice.Analyzer,
+	"unusedwrite":         unusedwrite.Analyzer,
+}
+
+// enabledAnalyzers is the set of analyzers enabled in this deployment.
+// It is set in main according to the analyzers setting.
+var enabledAnalyzers = map[string]bool{}
+
+// vettoolEnv is the environment variable that makes the server binary
+// run as a go vet tool instead; see runVettool.
+const vettoolEnv = "PLAY_VETTOOL"
+
+// parseAnalyzers returns the set of analyzers named in the
+// comma-separated list.
+func parseAnalyzers(list string) (map[string]bool, error) {
+	set := map[string]bool{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if analyzers[name] == nil {
+			return nil, fmt.Errorf("unknown analyzer %q", name)
+		}
+		set[name] = true
+	}
+	return set, nil
+}
+
+// checkAnalyzers reports an error if a request selects an analyzer that
+// is not enabled.
+func checkAnalyzers(names []string) error {
+	for _, name := range names {
+		if !enabledAnalyzers[name] {
+			return fmt.Errorf("analyzer %q is not enabled", name)
+		}
+	}
+	return nil
+}
+
+// runVettool runs the analyzers as a go vet tool, following the
+// protocol of unitchecker, if the vettoolEnv environment variable is
+// set. In that case it does not return.
+//
+// analyzeInDir runs go vet with the server binary as its tool, so that
+// the analyzers need not be installed separately.
+func runVettool() {
+	if os.Getenv(vettoolEnv) == "" {
+		return
+	}
+	var all []*analysis.Analyzer
+	for _, name := range analyzerNames() {
+		all = append(all, analyzers[name])
+	}
+	unitchecker.Main(all...)
+}
+
+// analyzerNames returns the sorted names of the analyzers.
+func analyzerNames() []string {
+	names := make([]string, 0, len(analyzers))
+	for name := range analyzers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// diagnostic is a finding of vet or of an analyzer.
+type diagnostic struct {
+	// File is the name of the file, relative to the snippet root.
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Analyzer string `json:"analyzer"`
+	Category string `json:"category,omitempty"`
+	Message  string `json:"message"`
+	// SuggestedFixes are alternative changes to the snippet that
+	// resolve the diagnostic, which the /fix endpoint applies.
+	SuggestedFixes []suggestedFix `json:"suggestedFixes,omitempty"`
+}
+
+// suggestedFix is a change to the snippet suggested by an analyzer.
+type suggestedFix struct {
+	Message string     `json:"message"`
+	Edits   []textEdit `json:"edits"`
+}
+
+// textEdit replaces the bytes from offset Start to End of a file with
+// New.
+type textEdit struct {
+	// File is the name of the file, relative to the snippet root.
+	File  string `json:"file"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	New   string `json:"new"`
+}
+
+// analyzeInDir runs the named analyzers on pkg in dir, as vetCheckInDir
+// runs go vet. The returned error is only about whether the analyzers
+// were able to run.
+func analyzeInDir(ctx context.Context, dir, pkg, goPath string, names []string) ([]diagnostic, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("error finding vet tool: %v", err)
+	}
+	// Selecting the analyzers with flags, rather than in the
+	// environment, makes them part of go vet's cache key.
+	args := []string{"-vettool=" + exe, "-json"}
+	for _, name := range names {
+		args = append(args, "-"+name+"=true")
+	}
+	release, err := acquireBuild(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	cmd := vetCommand(dir, pkg, goPath, args...)
+	cmd.Env = append(cmd.Env, vettoolEnv+"=1")
+	var buf bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &buf, &buf
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting go vet: %v", err)
+	}
+	if err := internal.WaitOrStop(ctx, cmd, os.Interrupt, 250*time.Millisecond); err != nil {
+		return nil, fmt.Errorf("error running analyzers: %v: %s", err, strings.Replace(buf.String(), dir, "", -1))
+	}
+	return parseDiagnostics(buf.Bytes(), dir)
+}
+
+// parseDiagnostics parses the output of go vet -json run in dir: a
+// sequence of JSON trees of diagnostics by package and analyzer, each
+// after comment lines naming the package. Suggested fixes that edit
+// files outside of dir are dropped.
+func parseDiagnostics(out []byte, dir string) ([]diagnostic, error) {
+	var jsonOut bytes.Buffer
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if !strings.HasPrefix(sc.Text(), "#") {
+			jsonOut.Write(sc.Bytes())
+			jsonOut.WriteByte('\n')
+		}
+	}
+	var diags []diagnostic
+	dec := json.NewDecoder(&jsonOut)
+	for {
+		var tree map[string]map[string]json.RawMessage
+		if err := dec.Decode(&tree); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error decoding analyzer output: %v", err)
+		}
+		for _, byAnalyzer := range tree {
+			for name, raw := range byAnalyzer {
+				var results []struct {
+					Category       string `json:"category"`
+					Posn           string `json:"posn"`
+					Message        string `json:"message"`
+					SuggestedFixes []struct {
+						Message string `json:"message"`
+						Edits   []struct {
+							Filename string `json:"filename"`
+							Start    int    `json:"start"`
+							End      int    `json:"end"`
+							New      string `json:"new"`
+						} `json:"edits"`
+					} `json:"suggested_fixes"`
+				}
+				if err := json.Unmarshal(raw, &results); err != nil {
+					// An analyzer failure is reported as
+					// {"error": {"err": message}}.
+					var failure struct {
+						Error struct{ Err string } `json:"error"`
+					}
+					json.Unmarshal(raw, &failure)
+					return nil, fmt.Errorf("analyzer %s: %s", name, failure.Error.Err)
+				}
+				for _, r := range results {
+					d := diagnostic{Analyzer: name, Category: r.Category, Message: r.Message}
+					d.File, d.Line, d.Column = parsePosn(r.Posn, dir)
+				fixes:
+					for _, f := range r.SuggestedFixes {
+						fix := suggestedFix{Message: f.Message}
+						for _, e := range f.Edits {
+							file := relativeFile(e.Filename, dir)
+							if filepath.IsAbs(file) {
+								// Files outside the snippet cannot be fixed.
+								continue fixes
+							}
+							fix.Edits = append(fix.Edits, textEdit{File: file, Start: e.Start, End: e.End, New: e.New})
+						}
+						d.SuggestedFixes = append(d.SuggestedFixes, fix)
+					}
+					diags = append(diags, d)
+				}
+			}
+		}
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i], diags[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Analyzer < b.Analyzer
+	})
+	return diags, nil
+}
+
+// posnRE matches the "file:line:col" position of a diagnostic, whose
+// line and column are omitted if unknown.
+var posnRE = regexp.MustCompile(`^(.*?)(?::(\d+))?(?::(\d+))?$`)
+
+// parsePosn parses the position of a diagnostic printed by go vet run
+// in dir.
+func parsePosn(posn, dir string) (file string, line, col int) {
+	m := posnRE.FindStringSubmatch(posn)
+	line, _ = strconv.Atoi(m[2])
+	col, _ = strconv.Atoi(m[3])
+	return relativeFile(m[1], dir), line, col
+}
@@ -0,0 +1,160 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMain(m *testing.M) {
+	// analyzeInDir runs the test binary as its vet tool, and
+	// TestSSATool as its -toolexec program.
+	runVettool()
+	runSSATool()
+	os.Exit(m.Run())
+}
+
+func TestParseAnalyzers(t *testing.T) {
+	got, err := parseAnalyzers("nilness, shadow,,nilness")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]bool{"nilness": true, "shadow": true}, got); diff != "" {
+		t.Errorf("parseAnalyzers mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := parseAnalyzers("nilness,nosuch"); err == nil {
+		t.Errorf("parseAnalyzers of an unknown analyzer succeeded")
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	const out = `# play
+# [play]
+{
+	"play": {
+		"shadow": [
+			{
+				"posn": "/tmp/sandbox1/prog.go:14:3",
+				"message": "declaration of \"err\" shadows declaration at line 12"
+			}
+		],
+		"nilness": [
+			{
+				"category": "nilderef",
+				"posn": "/tmp/sandbox1/prog.go:10:15",
+				"message": "nil dereference in load"
+			}
+		],
+		"stringintconv": [
+			{
+				"posn": "/tmp/sandbox1/prog.go:20:14",
+				"message": "conversion from int to string yields a string of one rune, not a string of digits",
+				"suggested_fixes": [
+					{
+						"message": "Format the number as a decimal",
+						"edits": [{"filename": "/tmp/sandbox1/prog.go", "start": 64, "end": 70, "new": "fmt.Sprint"}]
+					},
+					{
+						"message": "Edit the module cache",
+						"edits": [{"filename": "/root/go/pkg/mod/fmt.go", "start": 1, "end": 2, "new": "x"}]
+					}
+				]
+			}
+		]
+	}
+}
+# play/sub
+{
+	"play/sub": {
+		"unusedwrite": [
+			{
+				"posn": "/tmp/sandbox1/sub/sub.go:7",
+				"message": "unused write to field x"
+			}
+		]
+	}
+}
+`
+	want := []diagnostic{
+		{File: "prog.go", Line: 10, Column: 15, Analyzer: "nilness", Category: "nilderef", Message: "nil dereference in load"},
+		{File: "prog.go", Line: 14, Column: 3, Analyzer: "shadow", Message: `declaration of "err" shadows declaration at line 12`},
+		{
+			File: "prog.go", Line: 20, Column: 14, Analyzer: "stringintconv",
+			Message: "conversion from int to string yields a string of one rune, not a string of digits",
+			SuggestedFixes: []suggestedFix{{
+				Message: "Format the number as a decimal",
+				Edits:   []textEdit{{File: "prog.go", Start: 64, End: 70, New: "fmt.Sprint"}},
+			}},
+		},
+		{File: "sub/sub.go", Line: 7, Analyzer: "unusedwrite", Message: "unused write to field x"},
+	}
+	got, err := parseDiagnostics([]byte(out), "/tmp/sandbox1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseDiagnostics mismatch (-want +got):\n%s", diff)
+	}
+
+	const wantFormat = "./prog.go:10:15: nil dereference in load\n" +
+		"./prog.go:14:3: declaration of \"err\" shadows declaration at line 12\n" +
+		"./prog.go:20:14: conversion from int to string yields a string of one rune, not a string of digits\n" +
+		"./sub/sub.go:7: unused write to field x\n"
+	if got := formatDiagnostics(got); got != wantFormat {
+		t.Errorf("formatDiagnostics = %q; want %q", got, wantFormat)
+	}
+
+	const failure = `{"play": {"nilness": {"error": {"err": "internal error"}}}}`
+	if _, err := parseDiagnostics([]byte(failure), "/tmp/sandbox1"); err == nil {
+		t.Errorf("parseDiagnostics of an analyzer failure succeeded")
+	}
+}
+
+func TestAnalyzeInDir(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	const prog = `package main
+
+import "fmt"
+
+func f() error { return nil }
+
+func main() {
+	var p *int
+	if p == nil {
+		fmt.Println(*p)
+	}
+	err := f()
+	if true {
+		err := f()
+		fmt.Println(err)
+	}
+	fmt.Println(err)
+}
+`
+	dir := t.TempDir()
+	for name, data := range map[string]string{"go.mod": "module play\n", "prog.go": prog} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := analyzeInDir(context.Background(), dir, progName, os.Getenv("GOPATH"), []string{"shadow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []diagnostic{
+		{File: "prog.go", Line: 14, Column: 3, Analyzer: "shadow", Message: `declaration of "err" shadows declaration at line 12`},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("analyzeInDir mismatch (-want +got):\n%s", diff)
+	}
+}
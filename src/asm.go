@@ -0,0 +1,111 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// asmFunc is the assembly generated by the compiler for one function.
+type asmFunc struct {
+	Name  string
+	Instr []asmInstr
+}
+
+// asmInstr is a single assembly instruction and the source line
+// it was generated from.
+type asmInstr struct {
+	PC   int    // offset from the start of the function
+	File string // relative to the snippet root, unless outside of it
+	Line int    // 0 if the compiler did not record a line
+	Text string
+}
+
+// compileAsm builds the user program in req.Body with -gcflags=-S
+// and returns the generated assembly of the user's packages in
+// *response.Asm. The program is not run.
+func compileAsm(ctx context.Context, req *request) (*response, error) {
+	tmpDir, err := ioutil.TempDir("", "sandbox")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	br, err := sandboxBuild(ctx, tmpDir, []byte(req.Body), &buildOptions{gcflags: "-S"})
+	if err != nil {
+		return nil, err
+	}
+	if br.errorMessage != "" {
+		return &response{Errors: br.errorMessage, CompileErrors: br.compileErrors}, nil
+	}
+	defer br.cleanup()
+	return &response{
+		IsTest: br.testParam != "",
+		Asm:    parseAsm(br.output, tmpDir),
+	}, nil
+}
+
+var (
+	// asmFuncRE matches the header the compiler prints before the
+	// instructions of a function, such as
+	// "main.main STEXT size=115 args=0x0 locals=0x48 funcid=0x0".
+	asmFuncRE = regexp.MustCompile(`^(\S+) STEXT\b`)
+	// asmInstrRE matches a single instruction, such as
+	// "\t0x0004 00004 (/tmp/sandbox/prog.go:7)\tJLS\t108".
+	asmInstrRE = regexp.MustCompile(`^\t0x[0-9a-f]+ (\d+) \((.*?)\)\t(.*)$`)
+)
+
+// parseAsm parses the -S output of the compiler, as printed by go build
+// running in dir, into per-function listings. Data symbols, the
+// hexadecimal dump of each symbol, relocations and the FUNCDATA and
+// PCDATA pseudo-instructions are dropped.
+func parseAsm(out []byte, dir string) []asmFunc {
+	var (
+		funcs []asmFunc
+		cur   *asmFunc
+	)
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "\t") {
+			cur = nil
+			if m := asmFuncRE.FindStringSubmatch(line); m != nil {
+				funcs = append(funcs, asmFunc{Name: m[1]})
+				cur = &funcs[len(funcs)-1]
+			}
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		m := asmInstrRE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		text := strings.Replace(m[3], "\t", " ", -1)
+		if strings.HasPrefix(text, "FUNCDATA ") || strings.HasPrefix(text, "PCDATA ") {
+			continue
+		}
+		in := asmInstr{Text: text}
+		in.PC, _ = strconv.Atoi(m[1])
+		if i := strings.LastIndex(m[2], ":"); i > 0 {
+			if n, err := strconv.Atoi(m[2][i+1:]); err == nil {
+				in.File = relativeFile(m[2][:i], dir)
+				in.Line = n
+			}
+		}
+		cur.Instr = append(cur.Instr, in)
+	}
+	return funcs
+}
@@ -0,0 +1,46 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseAsm(t *testing.T) {
+	const out = `# play
+main.add STEXT nosplit size=4 align=0x0 args=0x10 locals=0x0 funcid=0x0
+	0x0000 00000 (/tmp/sandbox1/prog.go:5)	TEXT	main.add(SB), NOSPLIT|NOFRAME|ABIInternal, $0-16
+	0x0000 00000 (/tmp/sandbox1/prog.go:5)	FUNCDATA	$0, gclocals·g5+hNtRBP6YXNjfog7aZjQ==(SB)
+	0x0000 00000 (/tmp/sandbox1/prog.go:5)	PCDATA	$3, $1
+	0x0000 00000 (/tmp/sandbox1/prog.go:5)	ADDQ	BX, AX
+	0x0003 00003 (/tmp/sandbox1/prog.go:5)	RET
+	0x0000 48 01 d8 c3                                      H...
+main.main STEXT size=115 align=0x0 args=0x0 locals=0x48 funcid=0x0
+	0x0000 00000 (./prog.go:7)	TEXT	main.main(SB), ABIInternal, $72-0
+	0x001b 00027 (<unknown line number>)	NOP
+	0x0037 00055 (/usr/local/go-faketime/src/fmt/print.go:307)	MOVQ	os.Stdout(SB), BX
+	rel 2+0 t=R_USEIFACE type:string+0
+go:cuinfo.producer.main SDWARFCUINFO dupok size=0
+	0x0000 2d 4e                                            -N
+`
+	want := []asmFunc{
+		{Name: "main.add", Instr: []asmInstr{
+			{PC: 0, File: "prog.go", Line: 5, Text: "TEXT main.add(SB), NOSPLIT|NOFRAME|ABIInternal, $0-16"},
+			{PC: 0, File: "prog.go", Line: 5, Text: "ADDQ BX, AX"},
+			{PC: 3, File: "prog.go", Line: 5, Text: "RET"},
+		}},
+		{Name: "main.main", Instr: []asmInstr{
+			{PC: 0, File: "prog.go", Line: 7, Text: "TEXT main.main(SB), ABIInternal, $72-0"},
+			{PC: 27, Text: "NOP"},
+			{PC: 55, File: "/usr/local/go-faketime/src/fmt/print.go", Line: 307, Text: "MOVQ os.Stdout(SB), BX"},
+		}},
+	}
+	got := parseAsm([]byte(out), "/tmp/sandbox1")
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseAsm mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// compileError is a single diagnostic reported by the Go compiler or
+// the go command while building a user program.
+type compileError struct {
+	// File is the name of the file, relative to the snippet root.
+	// It is empty for errors that are not tied to a source position,
+	// such as module resolution failures.
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// compileOnly builds the user program in req.Body without running it.
+// Build failures are returned in *response.CompileErrors, in addition
+// to the flat *response.Errors string used by /compile.
+func compileOnly(ctx context.Context, req *request) (*response, error) {
+	tmpDir, err := ioutil.TempDir("", "sandbox")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	br, err := sandboxBuild(ctx, tmpDir, []byte(req.Body), &buildOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if br.errorMessage != "" {
+		errs := br.compileErrors
+		if len(errs) == 0 {
+			errs = []compileError{{Message: strings.TrimSpace(br.errorMessage)}}
+		}
+		return &response{Errors: br.errorMessage, CompileErrors: errs}, nil
+	}
+	defer br.cleanup()
+	waitVet := func() ([]diagnostic, string, error) { return nil, "", nil }
+	if req.WithVet {
+		waitVet = br.startVet(ctx, tmpDir)
+		defer waitVet()
+	}
+	waitAnalysis := func() ([]diagnostic, error) { return nil, nil }
+	if len(req.Analyzers) > 0 {
+		waitAnalysis = br.startAnalysis(ctx, tmpDir, req.Analyzers)
+		defer waitAnalysis()
+	}
+	vetDiags, vetOut, err := waitVet()
+	if err != nil {
+		return nil, err
+	}
+	diags, err := waitAnalysis()
+	if err != nil {
+		return nil, err
+	}
+	return &response{
+		IsTest:         br.testParam != "",
+		VetErrors:      vetOut,
+		VetOK:          req.WithVet && vetOut == "",
+		VetDiagnostics: vetDiags,
+		Diagnostics:    diags,
+	}, nil
+}
+
+// compileErrorRE matches a "file.go:line:col: message" diagnostic.
+// The column is optional; the go command omits it for some errors.
+var compileErrorRE = regexp.MustCompile(`^(.+?\.go):(\d+)(?::(\d+))?: (.*)$`)
+
+// parseCompileErrors parses the combined output of "go build" run in
+// dir into a list of compileErrors. File names inside dir are made
+// relative to it. Indented continuation lines are appended to the
+// message of the preceding diagnostic, and package banners ("# play")
+// are dropped. Any other line becomes a diagnostic with no position.
+func parseCompileErrors(out []byte, dir string) []compileError {
+	var errs []compileError
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			continue
+		case strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "\t") && len(errs) > 0:
+			last := &errs[len(errs)-1]
+			last.Message += "\n" + strings.TrimPrefix(line, "\t")
+			continue
+		}
+		m := compileErrorRE.FindStringSubmatch(line)
+		if m == nil {
+			errs = append(errs, compileError{Message: line})
+			continue
+		}
+		ce := compileError{File: relativeFile(m[1], dir), Message: m[4]}
+		ce.Line, _ = strconv.Atoi(m[2])
+		if m[3] != "" {
+			ce.Column, _ = strconv.Atoi(m[3])
+		}
+		errs = append(errs, ce)
+	}
+	return errs
+}
+
+// relativeFile rewrites a file name printed by the go command running
+// in dir so that it is relative to dir. Names outside of dir, such as
+// files in the module cache, are returned unchanged.
+func relativeFile(name, dir string) string {
+	if filepath.IsAbs(name) {
+		rel, err := filepath.Rel(dir, name)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return name
+		}
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(filepath.Clean(name))
+}
+
+// userPkgPattern returns the -gcflags package pattern, including the
+// trailing "=", that selects the user's packages in files when building
+// buildPkgArg. A single-file program is built as command-line-arguments,
+// which is what an unqualified flag applies to.
+func userPkgPattern(files *fileSet, buildPkgArg string) string {
+	if buildPkgArg == progName {
+		return ""
+	}
+	mod := modfile.ModulePath(files.Data(path.Join(buildPkgArg, "go.mod")))
+	if mod == "" {
+		return ""
+	}
+	return mod + "/...="
+}
+
+// workspaceMain returns the package argument that builds the main
+// module of the Go workspace defined by the go.work file in files.
+// That is the module whose use directive is marked with a "// main"
+// comment, if any, else the module at the root of the archive, if the
+// workspace uses it, else the first module the workspace uses.
+// The error, if any, is meant for the user.
+func workspaceMain(files *fileSet) (string, error) {
+	wf, err := modfile.ParseWork("go.work", files.Data("go.work"), nil)
+	if err != nil {
+		return "", err
+	}
+	if len(wf.Use) == 0 {
+		return "", errors.New("go.work: no use directives")
+	}
+	var main, root string
+	for _, u := range wf.Use {
+		dir := path.Clean(filepath.ToSlash(u.Path))
+		if path.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") {
+			return "", fmt.Errorf("go.work:%d: use %s: directory outside of the playground archive", u.Syntax.Start.Line, u.Path)
+		}
+		if dir == "." {
+			root = dir
+		}
+		if isMainUse(u) {
+			if main != "" {
+				return "", fmt.Errorf("go.work:%d: more than one use directive marked // main", u.Syntax.Start.Line)
+			}
+			main = dir
+		}
+	}
+	switch {
+	case main != "":
+	case root != "":
+		main = root
+	default:
+		main = path.Clean(filepath.ToSlash(wf.Use[0].Path))
+	}
+	if main == "." {
+		return ".", nil
+	}
+	return "./" + main, nil
+}
+
+// isMainUse reports whether u has a "// main" comment.
+func isMainUse(u *modfile.Use) bool {
+	if u.Syntax == nil {
+		return false
+	}
+	for _, c := range u.Syntax.Suffix {
+		if strings.TrimSpace(strings.TrimPrefix(c.Token, "//")) == "main" {
+			return true
+		}
+	}
+	return false
+}
+
+// modFlag returns the -mod flag for the go commands run on the snippet
+// written to dir: -mod=vendor if the snippet vendors its dependencies,
+// none in a workspace, where the go command only permits -mod=readonly
+// and -mod=vendor, and otherwise -mod=mod, so that the go command adds
+// missing requirements to go.mod.
+func modFlag(dir string) string {
+	switch {
+	case fileExists(filepath.Join(dir, "vendor", "modules.txt")):
+		return "-mod=vendor"
+	case fileExists(filepath.Join(dir, "go.work")):
+		return ""
+	}
+	return "-mod=mod"
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseCompileErrors(t *testing.T) {
+	const dir = "/tmp/sandbox123"
+	for _, tt := range []struct {
+		name string
+		out  string
+		want []compileError
+	}{
+		{
+			name: "empty",
+			out:  "",
+			want: nil,
+		},
+		{
+			name: "relative",
+			out:  "# play\n./prog.go:8:2: undefined: x\n./prog.go:9:1: missing return\n",
+			want: []compileError{
+				{File: "prog.go", Line: 8, Column: 2, Message: "undefined: x"},
+				{File: "prog.go", Line: 9, Column: 1, Message: "missing return"},
+			},
+		},
+		{
+			name: "absolute_subdir",
+			out:  "# play/foo\n/tmp/sandbox123/foo/foo.go:3:5: x declared and not used\n",
+			want: []compileError{
+				{File: "foo/foo.go", Line: 3, Column: 5, Message: "x declared and not used"},
+			},
+		},
+		{
+			name: "no_column",
+			out:  "prog.go:4: syntax error\n",
+			want: []compileError{
+				{File: "prog.go", Line: 4, Message: "syntax error"},
+			},
+		},
+		{
+			name: "continuation",
+			out:  "./prog.go:10:6: cannot use x (variable of type int) as string value:\n\tneed conversion\n",
+			want: []compileError{
+				{File: "prog.go", Line: 10, Column: 6, Message: "cannot use x (variable of type int) as string value:\nneed conversion"},
+			},
+		},
+		{
+			name: "outside_dir",
+			out:  "/tmp/gopath-1/pkg/mod/example.com/m@v1.0.0/m.go:1:1: expected 'package', found 'EOF'\n",
+			want: []compileError{
+				{File: "/tmp/gopath-1/pkg/mod/example.com/m@v1.0.0/m.go", Line: 1, Column: 1, Message: "expected 'package', found 'EOF'"},
+			},
+		},
+		{
+			name: "no_position",
+			out:  "go: example.com/m@v1.0.0: reading example.com/m/go.mod: 404 Not Found\n",
+			want: []compileError{
+				{Message: "go: example.com/m@v1.0.0: reading example.com/m/go.mod: 404 Not Found"},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseCompileErrors([]byte(tt.out), dir)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("parseCompileErrors mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWorkspaceMain(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		goWork  string
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "first",
+			goWork: "go 1.18\n\nuse (\n\t./app\n\t./lib\n)\n",
+			want:   "./app",
+		},
+		{
+			name:   "root",
+			goWork: "go 1.18\n\nuse ./lib\nuse .\n",
+			want:   ".",
+		},
+		{
+			name:   "marked",
+			goWork: "go 1.18\n\nuse (\n\t.\n\t./lib\n\t./cmd/tool // main\n)\n",
+			want:   "./cmd/tool",
+		},
+		{
+			name:    "marked_twice",
+			goWork:  "go 1.18\n\nuse ./a // main\nuse ./b // main\n",
+			wantErr: "go.work:4: more than one use directive marked // main",
+		},
+		{
+			name:    "outside",
+			goWork:  "go 1.18\n\nuse ../elsewhere\n",
+			wantErr: "go.work:3: use ../elsewhere: directory outside of the playground archive",
+		},
+		{
+			name:    "absolute",
+			goWork:  "go 1.18\n\nuse /etc\n",
+			wantErr: "go.work:3: use /etc: directory outside of the playground archive",
+		},
+		{
+			name:    "no_use",
+			goWork:  "go 1.18\n",
+			wantErr: "go.work: no use directives",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			files := new(fileSet)
+			files.AddFile("go.work", []byte(tt.goWork))
+			got, err := workspaceMain(files)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("workspaceMain() = %q, %v; want error %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("workspaceMain() = %q, %v; want %q, nil", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserPkgPattern(t *testing.T) {
+	files := new(fileSet)
+	files.AddFile("go.mod", []byte("module play\n"))
+	files.AddFile("tools/go.mod", []byte("module example.com/tools\n"))
+	for _, tt := range []struct {
+		buildPkgArg, want string
+	}{
+		{progName, ""},
+		{".", "play/...="},
+		{"./tools", "example.com/tools/...="},
+		{"./missing", ""},
+	} {
+		if got := userPkgPattern(files, tt.buildPkgArg); got != tt.want {
+			t.Errorf("userPkgPattern(files, %q) = %q; want %q", tt.buildPkgArg, got, tt.want)
+		}
+	}
+}
+
+func TestModFlag(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"module", []string{"go.mod"}, "-mod=mod"},
+		{"workspace", []string{"go.work", "a/go.mod"}, ""},
+		{"vendor", []string{"go.mod", "vendor/modules.txt"}, "-mod=vendor"},
+		{"workspace_vendor", []string{"go.work", "a/go.mod", "vendor/modules.txt"}, "-mod=vendor"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tt.files {
+				f = filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(f, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := modFlag(dir); got != tt.want {
+				t.Errorf("modFlag() = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// errBuildQueueFull is returned by sandboxBuild, and by the functions
+// running go vet, the analyzers and govulncheck, when all build workers
+// are busy and the queue of builds waiting for one is full.
+var errBuildQueueFull = errors.New("build queue full")
+
+// buildRetryAfter is the Retry-After value sent with the 429 response
+// for errBuildQueueFull.
+const buildRetryAfter = 10 * time.Second
+
+// builds bounds the number of go commands run at once for requests:
+// builds, go vet, the analyzers and govulncheck. It is replaced in main
+// according to the -build-workers and -build-queue flags.
+var builds = newBuildPool(runtime.NumCPU(), 10*runtime.NumCPU())
+
+// buildPool is a pool of build workers with a bounded FIFO queue of
+// builds waiting for a worker.
+type buildPool struct {
+	workers  int // maximum number of concurrent builds
+	maxQueue int // maximum number of waiting builds
+
+	mu      sync.Mutex
+	running int
+	queue   []*buildWaiter
+}
+
+// buildWaiter is a build waiting in a buildPool's queue.
+type buildWaiter struct {
+	ready    chan struct{} // closed when the build is handed a worker
+	moved    chan struct{} // signaled when position changes
+	position int           // 1-based position in the queue; guarded by buildPool.mu
+}
+
+func newBuildPool(workers, maxQueue int) *buildPool {
+	if workers < 1 {
+		workers = 1
+	}
+	if maxQueue < 0 {
+		maxQueue = 0
+	}
+	return &buildPool{workers: workers, maxQueue: maxQueue}
+}
+
+// acquireBuild waits for a worker of builds, as builds.acquire does,
+// and then starts using the shared caches, if any. It returns the
+// function to call to release both. Every go command run for a request
+// takes them in this order: a trim waiting to remove cache entries
+// blocks new users of the caches until the current ones are done, so a
+// command holding the caches while it waits for a worker could
+// deadlock with the builds that hold the workers.
+func acquireBuild(ctx context.Context, queued func(position int)) (release func(), err error) {
+	releaseWorker, err := builds.acquire(ctx, queued)
+	if err != nil {
+		return nil, err
+	}
+	if caches == nil {
+		return releaseWorker, nil
+	}
+	done := caches.use()
+	return func() {
+		done()
+		releaseWorker()
+	}, nil
+}
+
+// acquire waits for a build worker and returns the function to call
+// to release it. While the build waits, queued, if non-nil, is called
+// with its 1-based position in the queue when it changes, and with 0
+// once it leaves the queue for a worker. The calls are made in order by
+// the waiting goroutine, so positions are never reported out of order,
+// though a slow queued may miss intermediate ones. If the queue is
+// full, acquire returns errBuildQueueFull without waiting.
+func (p *buildPool) acquire(ctx context.Context, queued func(position int)) (release func(), err error) {
+	start := time.Now()
+	outcome := "started"
+	defer func() {
+		// Ignore error. The only error can be invalid tag key or value
+		// length, which we know are safe.
+		stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(kGoBuildQueueOutcome, outcome)},
+			mGoBuildQueueLatency.M(float64(time.Since(start))/float64(time.Millisecond)))
+	}()
+	if queued == nil {
+		queued = func(int) {}
+	}
+
+	p.mu.Lock()
+	if p.running < p.workers && len(p.queue) == 0 {
+		p.running++
+		p.mu.Unlock()
+		return p.release, nil
+	}
+	if len(p.queue) >= p.maxQueue {
+		p.mu.Unlock()
+		outcome = "rejected"
+		return nil, errBuildQueueFull
+	}
+	w := &buildWaiter{ready: make(chan struct{}), moved: make(chan struct{}, 1)}
+	p.queue = append(p.queue, w)
+	w.position = len(p.queue)
+	reported := w.position
+	p.mu.Unlock()
+	queued(reported)
+
+	for {
+		select {
+		case <-w.ready:
+			queued(0)
+			return p.release, nil
+		case <-w.moved:
+			p.mu.Lock()
+			position := w.position
+			p.mu.Unlock()
+			// Position 0 means the build was handed a worker,
+			// which ready reports.
+			if position != 0 && position != reported {
+				reported = position
+				queued(position)
+			}
+			continue
+		case <-ctx.Done():
+		}
+		break
+	}
+	outcome = "canceled"
+	p.mu.Lock()
+	for i, qw := range p.queue {
+		if qw == w {
+			p.queue = append(p.queue[:i], p.queue[i+1:]...)
+			p.renumber(i)
+			p.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	p.mu.Unlock()
+	// We were handed a worker just as ctx was done; pass it on.
+	p.release()
+	return nil, ctx.Err()
+}
+
+// release hands the caller's worker to the first queued build, if
+// any, or returns it to the pool.
+func (p *buildPool) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.queue) == 0 {
+		p.running--
+		return
+	}
+	next := p.queue[0]
+	p.queue = p.queue[1:]
+	next.position = 0
+	close(next.ready)
+	p.renumber(0)
+}
+
+// renumber updates the positions of the waiters from index i on in the
+// queue and signals them. It does not wait for them to report their
+// positions, so slow observers do not block the pool. p.mu must be
+// held.
+func (p *buildPool) renumber(i int) {
+	for j, w := range p.queue[i:] {
+		w.position = i + j + 1
+		select {
+		case w.moved <- struct{}{}:
+		default:
+		}
+	}
+}
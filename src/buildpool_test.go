@@ -0,0 +1,178 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildPool(t *testing.T) {
+	p := newBuildPool(1, 2)
+	ctx := context.Background()
+
+	// positions records the queue positions reported to each build.
+	var (
+		mu        sync.Mutex
+		positions = map[string][]int{}
+	)
+	queued := func(name string) func(int) {
+		return func(pos int) {
+			mu.Lock()
+			defer mu.Unlock()
+			positions[name] = append(positions[name], pos)
+		}
+	}
+	waitQueued := func(n int) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			p.mu.Lock()
+			q := len(p.queue)
+			p.mu.Unlock()
+			if q == n {
+				return
+			}
+		}
+		t.Fatalf("queue did not reach length %d", n)
+	}
+
+	releaseA, err := p.acquire(ctx, queued("a"))
+	if err != nil {
+		t.Fatalf("acquire a: %v", err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	bDone := make(chan error)
+	go func() {
+		_, err := p.acquire(cctx, queued("b"))
+		bDone <- err
+	}()
+	waitQueued(1)
+	cDone := make(chan func())
+	go func() {
+		release, err := p.acquire(ctx, queued("c"))
+		if err != nil {
+			t.Errorf("acquire c: %v", err)
+		}
+		cDone <- release
+	}()
+	waitQueued(2)
+
+	if _, err := p.acquire(ctx, queued("d")); !errors.Is(err, errBuildQueueFull) {
+		t.Errorf("acquire with a full queue: got %v, want errBuildQueueFull", err)
+	}
+
+	// b gives up; c moves up the queue, then gets a's worker.
+	cancel()
+	if err := <-bDone; !errors.Is(err, context.Canceled) {
+		t.Errorf("acquire b: got %v, want context.Canceled", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		mu.Lock()
+		n := len(positions["c"])
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("c was not told its new position")
+		}
+	}
+	releaseA()
+	releaseC := <-cDone
+	releaseC()
+
+	if p.running != 0 || len(p.queue) != 0 {
+		t.Errorf("after releasing all: running = %d, queued = %d; want 0, 0", p.running, len(p.queue))
+	}
+	want := map[string][]int{
+		"b": {1},
+		"c": {2, 1, 0},
+	}
+	if diff := cmp.Diff(want, positions); diff != "" {
+		t.Errorf("reported positions mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestAcquireBuildTrim trims the shared caches while every build worker
+// is busy and builds wait for one: the trim waits for the build using
+// the caches, and the waiting builds, which do not use the caches yet,
+// then get a worker in turn.
+func TestAcquireBuildTrim(t *testing.T) {
+	defer func(b *buildPool, c *sharedCaches) { builds, caches = b, c }(builds, caches)
+	c, err := newSharedCaches(t.TempDir(), 1, 1<<30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := filepath.Join(c.goCache, "stale")
+	if err := os.WriteFile(stale, []byte("stale entry"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	builds, caches = newBuildPool(1, 2), c
+	ctx := context.Background()
+
+	releaseA, err := acquireBuild(ctx, nil)
+	if err != nil {
+		t.Fatalf("acquireBuild a: %v", err)
+	}
+	done := make(chan string, 3)
+	build := func(name string) {
+		release, err := acquireBuild(ctx, nil)
+		if err != nil {
+			t.Errorf("acquireBuild %s: %v", name, err)
+		} else {
+			release()
+		}
+		done <- name
+	}
+	waitQueued := func(n int) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			builds.mu.Lock()
+			q := len(builds.queue)
+			builds.mu.Unlock()
+			if q == n {
+				return
+			}
+		}
+		t.Fatalf("queue did not reach length %d", n)
+	}
+	go build("b")
+	waitQueued(1)
+	go func() {
+		c.trim()
+		done <- "trim"
+	}()
+	// Wait for the trim to wait for the caches, which blocks new users.
+	for deadline := time.Now().Add(5 * time.Second); c.mu.TryRLock(); time.Sleep(time.Millisecond) {
+		c.mu.RUnlock()
+		if time.Now().After(deadline) {
+			t.Fatal("trim did not wait for the caches")
+		}
+	}
+	go build("c")
+	waitQueued(2)
+
+	releaseA()
+	var got []string
+	for i := 0; i < 3; i++ {
+		select {
+		case name := <-done:
+			got = append(got, name)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("deadlock: only %v finished", got)
+		}
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale build cache entry not trimmed: %v", err)
+	}
+}
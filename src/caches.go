@@ -0,0 +1,314 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/playground/internal"
+)
+
+// cacheTrimPeriod is how often the shared caches are trimmed.
+const cacheTrimPeriod = 10 * time.Minute
+
+// caches holds the build and module caches shared by all builds.
+// It is set in main according to the -cache-dir flag; if nil, each
+// build uses a fresh GOCACHE and module cache of its own.
+var caches *sharedCaches
+
+// sharedCaches is a GOCACHE and a GOMODCACHE shared by all builds, so
+// that dependencies are compiled and downloaded once rather than for
+// every build. The go command supports concurrent use of both; builds
+// and the removals of trim are serialized with a RWMutex so that
+// trimming never removes files from under a running build.
+type sharedCaches struct {
+	goCache, modCache string
+	// maxGoCacheSize and maxModCacheSize bound the size in bytes
+	// of each cache, as enforced by trim.
+	maxGoCacheSize, maxModCacheSize int64
+
+	mu sync.RWMutex // held for reading by builds, for writing by trim's removals
+}
+
+// newSharedCaches returns the caches in subdirectories of dir,
+// creating them if needed.
+func newSharedCaches(dir string, maxGoCacheSize, maxModCacheSize int64) (*sharedCaches, error) {
+	c := &sharedCaches{
+		goCache:         filepath.Join(dir, "gocache"),
+		modCache:        filepath.Join(dir, "modcache"),
+		maxGoCacheSize:  maxGoCacheSize,
+		maxModCacheSize: maxModCacheSize,
+	}
+	for _, d := range []string{c.goCache, c.modCache} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// use marks the start of a build using the caches and returns the
+// function to call once the build is done. Calls may not be nested.
+// Builds call it through acquireBuild, once they have a build worker.
+func (c *sharedCaches) use() (done func()) {
+	c.mu.RLock()
+	return c.mu.RUnlock
+}
+
+// trimEvery trims the caches every period until ctx is done.
+func (c *sharedCaches) trimEvery(ctx context.Context, period time.Duration) {
+	internal.PeriodicallyDo(ctx, period, func(context.Context, time.Time) {
+		c.trim()
+	})
+}
+
+// cacheEntry is a unit of removal from a cache: a file of the build
+// cache, or the files of a module version in the module cache.
+type cacheEntry struct {
+	paths []string // removed in order, directories with their contents
+	size  int64
+	// used is when the entry was last used, as the modification time
+	// of usedPath.
+	used     time.Time
+	usedPath string
+}
+
+// trim brings the caches back under their size bounds, removing the
+// least recently used entries of a cache over its bound until it is
+// under three quarters of it. In the build cache, the go command
+// updates the modification time of the entries it uses. In the module
+// cache, entries are whole module versions, with their download files
+// and extracted directory, and builds record their use with markUsed.
+//
+// The caches are walked without holding c.mu, so that builds do not
+// stall meanwhile; it is only held to remove the entries, which are
+// skipped if used since they were chosen.
+func (c *sharedCaches) trim() {
+	if entries, total, err := buildCacheEntries(c.goCache); err != nil {
+		log.Printf("error reading build cache: %v", err)
+	} else if total > c.maxGoCacheSize {
+		c.remove(lruVictims(entries, total, c.maxGoCacheSize*3/4))
+	}
+
+	total, err := dirSize(c.modCache)
+	if err != nil || total <= c.maxModCacheSize {
+		return
+	}
+	entries, err := moduleCacheEntries(c.modCache)
+	if err != nil {
+		log.Printf("error reading module cache: %v", err)
+		return
+	}
+	log.Printf("module cache over %d bytes; removing least recently used modules", c.maxModCacheSize)
+	c.remove(lruVictims(entries, total, c.maxModCacheSize*3/4))
+}
+
+// lruVictims returns the least recently used of entries, of total
+// size total, to remove to bring the size to at most max.
+func lruVictims(entries []cacheEntry, total, max int64) []cacheEntry {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].used.Before(entries[j].used) })
+	var victims []cacheEntry
+	for _, e := range entries {
+		if total <= max {
+			break
+		}
+		victims = append(victims, e)
+		total -= e.size
+	}
+	return victims
+}
+
+// remove removes the entries that were not used since they were
+// chosen, holding c.mu.
+func (c *sharedCaches) remove(entries []cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range entries {
+		if fi, err := os.Stat(e.usedPath); err == nil && fi.ModTime().After(e.used) {
+			continue
+		}
+		for _, path := range e.paths {
+			if err := removeAll(path); err != nil {
+				log.Printf("error trimming cache: %v", err)
+			}
+		}
+	}
+}
+
+// buildCacheEntries returns the files of the build cache in dir, and
+// their total size.
+func buildCacheEntries(dir string) (entries []cacheEntry, total int64, err error) {
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil // removed meanwhile
+		}
+		entries = append(entries, cacheEntry{paths: []string{path}, size: fi.Size(), used: fi.ModTime(), usedPath: path})
+		total += fi.Size()
+		return nil
+	})
+	return entries, total, err
+}
+
+// moduleCacheEntries returns the module versions in the module cache
+// dir. A module version is known from the files of its download cache,
+// cache/download/<module>/@v/<version>.{info,mod,zip,ziphash,...}; it
+// was last used when its .info file, or else its .mod file, was last
+// modified. Its size includes that of its extracted directory,
+// <module>@<version>.
+func moduleCacheEntries(dir string) ([]cacheEntry, error) {
+	download := filepath.Join(dir, "cache", "download")
+	byVersion := map[string]*cacheEntry{}
+	var keys []string
+	err := filepath.WalkDir(download, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == download {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Base(filepath.Dir(path)) != "@v" {
+			return nil
+		}
+		name := d.Name()
+		ext := filepath.Ext(name)
+		if version := strings.TrimSuffix(name, ext); version == "list" || version == "" {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil // removed meanwhile
+		}
+		key := filepath.Join(filepath.Dir(path), strings.TrimSuffix(name, ext))
+		e := byVersion[key]
+		if e == nil {
+			e = &cacheEntry{}
+			byVersion[key] = e
+			keys = append(keys, key)
+		}
+		e.paths = append(e.paths, path)
+		e.size += fi.Size()
+		if ext == ".info" || (ext == ".mod" && !strings.HasSuffix(e.usedPath, ".info")) {
+			e.used, e.usedPath = fi.ModTime(), path
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]cacheEntry, 0, len(keys))
+	for _, key := range keys {
+		e := byVersion[key]
+		// The extracted module, removed first so that the go
+		// command never finds it without its download files.
+		rel, err := filepath.Rel(download, key)
+		if err != nil {
+			continue
+		}
+		module, version := filepath.Dir(filepath.Dir(rel)), filepath.Base(rel)
+		extracted := filepath.Join(dir, module+"@"+version)
+		if size, err := dirSize(extracted); err == nil {
+			e.size += size
+			e.paths = append([]string{extracted}, e.paths...)
+		}
+		if e.usedPath == "" {
+			e.usedPath = e.paths[len(e.paths)-1]
+		}
+		entries = append(entries, *e)
+	}
+	return entries, nil
+}
+
+// markUsed records that a build used the modules listed in the go.sum
+// files sums, so that trim removes them last. The go command does not
+// record the use of modules in the module cache.
+func (c *sharedCaches) markUsed(sums [][]byte) {
+	now := time.Now()
+	seen := map[string]bool{}
+	for _, sum := range sums {
+		for _, line := range strings.Split(string(sum), "\n") {
+			f := strings.Fields(line)
+			if len(f) != 3 {
+				continue
+			}
+			path, version := f[0], strings.TrimSuffix(f[1], "/go.mod")
+			if seen[path+"@"+version] {
+				continue
+			}
+			seen[path+"@"+version] = true
+			ep, err := module.EscapePath(path)
+			if err != nil {
+				continue
+			}
+			ev, err := module.EscapeVersion(version)
+			if err != nil {
+				continue
+			}
+			base := filepath.Join(c.modCache, "cache", "download", ep, "@v", ev)
+			for _, ext := range []string{".info", ".mod"} {
+				os.Chtimes(base+ext, now, now)
+			}
+		}
+	}
+}
+
+// goSums returns the contents of the go.sum files of the snippet files
+// built in dir: those of its modules and the go.work.sum file of its
+// workspace, once updated by the build.
+func goSums(dir string, files *fileSet) [][]byte {
+	names := []string{"go.sum", "go.work.sum"}
+	for _, f := range files.files {
+		if path.Base(f) == "go.mod" && path.Dir(f) != "." {
+			names = append(names, path.Join(path.Dir(f), "go.sum"))
+		}
+	}
+	var sums [][]byte
+	for _, name := range names {
+		if data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			sums = append(sums, data)
+		}
+	}
+	return sums
+}
+
+// dirSize returns the total size of the files in dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if fi, err := d.Info(); err == nil {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// removeAll removes path and, if it is a directory, its contents.
+// Modules are extracted read-only unless built with -modcacherw, so
+// write permission is restored first.
+func removeAll(path string) error {
+	filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			os.Chmod(path, 0755)
+		}
+		return nil
+	})
+	return os.RemoveAll(path)
+}
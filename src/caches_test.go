@@ -0,0 +1,123 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSharedCachesTrim(t *testing.T) {
+	c, err := newSharedCaches(t.TempDir(), 400, 350)
+	if err != nil {
+		t.Fatalf("newSharedCaches: %v", err)
+	}
+	write := func(name string, size int, age time.Duration) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(-age)
+		if err := os.Chtimes(name, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(c.goCache, "00", "old-d"), 100, 3*time.Hour)
+	write(filepath.Join(c.goCache, "01", "older-d"), 100, 4*time.Hour)
+	write(filepath.Join(c.goCache, "00", "new-d"), 100, time.Hour)
+	write(filepath.Join(c.goCache, "02", "newest-d"), 100, 0)
+	// Module m, with its version list, download files and extracted
+	// directory, was used two hours ago.
+	writeModule := func(mod string, age time.Duration) {
+		t.Helper()
+		v := filepath.Join(c.modCache, "cache", "download", "example.com", mod, "@v")
+		write(filepath.Join(v, "list"), 10, age)
+		write(filepath.Join(v, "v1.0.0.zip"), 60, age)
+		write(filepath.Join(v, "v1.0.0.mod"), 10, age)
+		write(filepath.Join(v, "v1.0.0.info"), 10, age)
+		write(filepath.Join(c.modCache, "example.com", mod+"@v1.0.0", mod+".go"), 100, age)
+	}
+	writeModule("m", 2*time.Hour)
+
+	// Within bounds, nothing is removed.
+	c.trim()
+	if got := files(t, c.goCache); len(got) != 4 {
+		t.Errorf("build cache within bound was trimmed to %v", got)
+	}
+	if got := files(t, c.modCache); len(got) != 5 {
+		t.Errorf("module cache within bound was trimmed to %v", got)
+	}
+
+	// Over bounds: the build cache loses its oldest entries down to 300
+	// bytes, and the module cache its least recently used module down
+	// to 262 bytes. A build used m after n was downloaded, so n goes.
+	write(filepath.Join(c.goCache, "03", "extra-d"), 100, 2*time.Hour)
+	writeModule("n", time.Hour)
+	os.Chmod(filepath.Join(c.modCache, "example.com", "n@v1.0.0"), 0555)
+	c.markUsed([][]byte{[]byte("example.com/m v1.0.0 h1:abc=\nexample.com/m v1.0.0/go.mod h1:def=\n")})
+	c.trim()
+	if diff := cmp.Diff([]string{"00/new-d", "02/newest-d", "03/extra-d"}, files(t, c.goCache)); diff != "" {
+		t.Errorf("trimmed build cache mismatch (-want +got):\n%s", diff)
+	}
+	want := []string{
+		"cache/download/example.com/m/@v/list",
+		"cache/download/example.com/m/@v/v1.0.0.info",
+		"cache/download/example.com/m/@v/v1.0.0.mod",
+		"cache/download/example.com/m/@v/v1.0.0.zip",
+		"cache/download/example.com/n/@v/list",
+		"example.com/m@v1.0.0/m.go",
+	}
+	if diff := cmp.Diff(want, files(t, c.modCache)); diff != "" {
+		t.Errorf("trimmed module cache mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGoSums(t *testing.T) {
+	dir := t.TempDir()
+	files, err := splitFiles([]byte("-- go.mod --\nmodule play\n-- sub/go.mod --\nmodule sub\n-- prog.go --\npackage main\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"go.sum": "a", "sub/go.sum": "b", "other/go.sum": "c"} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	for _, sum := range goSums(dir, files) {
+		got = append(got, string(sum))
+	}
+	if diff := cmp.Diff([]string{"a", "b"}, got); diff != "" {
+		t.Errorf("goSums mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// files returns the names of the files in dir, relative to it.
+func files(t *testing.T, dir string) []string {
+	t.Helper()
+	var names []string
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,167 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/playground/internal"
+)
+
+// A test program built for coverage is instrumented by "go tool cover",
+// which counts the runs of each block of statements in coverVar. After
+// the tests and examples, an example named coverExample writes the
+// counts as a coverage profile to coverFile in the output directory of
+// the sandbox, which returns it with the run's output; see getTestProg.
+// stripHiddenOutput removes the test output about the example.
+const (
+	coverVar     = "playgroundCover"
+	coverExample = hiddenExamplePrefix + "Coverage"
+	coverFile    = "coverage.out"
+)
+
+// fileCoverage is the test coverage of a file of the program, by line.
+type fileCoverage struct {
+	File string `json:"file"`
+	// Covered are the lines whose statements all ran, and Uncovered
+	// those with statements that did not run. Lines without
+	// statements are in neither.
+	Covered   []lineRange `json:"covered,omitempty"`
+	Uncovered []lineRange `json:"uncovered,omitempty"`
+	// Percent is the percentage of the statements of the file that
+	// ran, as reported by "go test -cover".
+	Percent float64 `json:"percent"`
+}
+
+// lineRange is the lines from Start to End, inclusive, numbered from 1.
+type lineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// instrumentCoverage rewrites progName in the directory of the build
+// command with "go tool cover", using the go command and environment of
+// build. If the program cannot be instrumented, the returned message
+// explains why to the user.
+func instrumentCoverage(ctx context.Context, build *exec.Cmd) (msg string, err error) {
+	const out = "prog.cover.go"
+	cmd := exec.Command(build.Path, "tool", "cover", "-mode=count", "-var="+coverVar, "-o", out, progName)
+	cmd.Dir, cmd.Env = build.Dir, build.Env
+	var stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = &stderr, &stderr
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("error starting go tool cover: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, maxBuildTime)
+	defer cancel()
+	if err := internal.WaitOrStop(ctx, cmd, os.Interrupt, 250*time.Millisecond); err != nil {
+		if ee := (*exec.ExitError)(nil); !errors.As(err, &ee) {
+			return "", fmt.Errorf("error running go tool cover: %v", err)
+		}
+		return strings.Replace(stderr.String(), build.Dir+"/", "", -1), nil
+	}
+	if err := os.Rename(filepath.Join(build.Dir, out), filepath.Join(build.Dir, progName)); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+// coverBlockRE matches a block of a coverage profile:
+// file:startLine.startCol,endLine.endCol statements count
+var coverBlockRE = regexp.MustCompile(`^(.+):(\d+)\.(\d+),(\d+)\.(\d+) (\d+) (\d+)$`)
+
+// parseCoverProfile parses a coverage profile in count mode into the
+// coverage of each file, by line. Blocks that start after line lines,
+// which are of the code added to run the tests, are ignored.
+func parseCoverProfile(profile string, lines int) ([]fileCoverage, error) {
+	const (
+		covered = iota + 1
+		uncovered
+	)
+	type fileState struct {
+		line       []int // by line number; uncovered wins over covered
+		stmts, ran int
+	}
+	files := map[string]*fileState{}
+	sc := bufio.NewScanner(strings.NewReader(profile))
+	for sc.Scan() {
+		text := sc.Text()
+		if text == "" || strings.HasPrefix(text, "mode:") {
+			continue
+		}
+		m := coverBlockRE.FindStringSubmatch(text)
+		if m == nil {
+			return nil, fmt.Errorf("bad coverage profile line %q", text)
+		}
+		var n [6]int
+		for i := range n {
+			n[i], _ = strconv.Atoi(m[i+2])
+		}
+		startLine, endLine, endCol, stmts, count := n[0], n[2], n[3], n[4], n[5]
+		if startLine > lines || stmts == 0 {
+			continue
+		}
+		f := files[m[1]]
+		if f == nil {
+			f = &fileState{line: make([]int, lines+1)}
+			files[m[1]] = f
+		}
+		f.stmts += stmts
+		state := uncovered
+		if count > 0 {
+			f.ran += stmts
+			state = covered
+		}
+		// A block ending in column 1 has nothing on its last line.
+		if endCol <= 1 && endLine > startLine {
+			endLine--
+		}
+		if endLine > lines {
+			endLine = lines
+		}
+		for l := startLine; l <= endLine; l++ {
+			if f.line[l] != uncovered {
+				f.line[l] = state
+			}
+		}
+	}
+	var cov []fileCoverage
+	for name, f := range files {
+		fc := fileCoverage{File: name}
+		if f.stmts > 0 {
+			fc.Percent = 100 * float64(f.ran) / float64(f.stmts)
+		}
+		for l := 1; l <= lines; l++ {
+			var ranges *[]lineRange
+			switch f.line[l] {
+			case covered:
+				ranges = &fc.Covered
+			case uncovered:
+				ranges = &fc.Uncovered
+			default:
+				continue
+			}
+			if k := len(*ranges) - 1; k >= 0 && (*ranges)[k].End == l-1 {
+				(*ranges)[k].End = l
+			} else {
+				*ranges = append(*ranges, lineRange{Start: l, End: l})
+			}
+		}
+		cov = append(cov, fc)
+	}
+	sort.Slice(cov, func(i, j int) bool { return cov[i].File < cov[j].File })
+	return cov, nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/playground/sandbox/sandboxtypes"
+)
+
+func TestParseCoverProfile(t *testing.T) {
+	const profile = `mode: count
+prog.go:5.21,6.11 1 3
+prog.go:9.2,9.10 1 2
+prog.go:6.11,8.3 1 0
+prog.go:12.28,13.16 1 1
+prog.go:13.16,15.3 1 0
+prog.go:19.13,21.2 2 1
+`
+	got, err := parseCoverProfile(profile, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []fileCoverage{{
+		File:      "prog.go",
+		Covered:   []lineRange{{5, 5}, {9, 9}, {12, 12}},
+		Uncovered: []lineRange{{6, 8}, {13, 15}},
+		Percent:   60,
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseCoverProfile mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := parseCoverProfile("mode: count\nprog.go:1.1 1\n", 1); err == nil {
+		t.Errorf("parseCoverProfile of a bad line succeeded")
+	}
+}
+
+// TestCoverage builds and runs a test program instrumented for
+// coverage with the local go command.
+func TestCoverage(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	const src = `package main
+
+import "testing"
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func TestAbs(t *testing.T) {
+	if abs(2) != 2 {
+		t.Fatal("abs(2) != 2")
+	}
+}
+`
+	want := []fileCoverage{{
+		File:      "prog.go",
+		Covered:   []lineRange{{6, 6}, {9, 9}, {13, 13}},
+		Uncovered: []lineRange{{7, 7}, {14, 14}},
+		Percent:   60,
+	}}
+	for _, tt := range []struct {
+		name string
+		opt  *buildOptions
+	}{
+		{"Coverage", &buildOptions{coverage: true}},
+		{"CoverageAndProfile", &buildOptions{coverage: true, profile: "cpu", trace: true}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := splitFiles([]byte(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files.AddFile(progName, getTestProg([]byte(src), tt.opt))
+			if tt.opt.profile != "" {
+				addProfiling(files, progName, tt.opt, true)
+			}
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, progName), files.Data(progName), 0644); err != nil {
+				t.Fatal(err)
+			}
+			build := exec.Command(goBin, "build", "-o", "a.out", progName)
+			build.Dir = dir
+			build.Env = append(os.Environ(), "GO111MODULE=off", "GOFLAGS=")
+			if msg, err := instrumentCoverage(context.Background(), build); err != nil || msg != "" {
+				t.Fatalf("instrumentCoverage: %q, %v", msg, err)
+			}
+			if out, err := build.CombinedOutput(); err != nil {
+				t.Fatalf("go build: %v\n%s", err, out)
+			}
+			outDir := t.TempDir()
+			run := exec.Command(filepath.Join(dir, "a.out"), "-test.v")
+			run.Env = append(os.Environ(), sandboxtypes.OutputDirEnv+"="+outDir)
+			out, err := run.Output()
+			if err != nil {
+				t.Fatalf("running test program: %v\n%s", err, out)
+			}
+
+			events := []Event{{Message: string(out), Kind: "stdout"}}
+			if rest := stripHiddenOutput(events); len(rest) != 1 || strings.Contains(rest[0].Message, "playground") {
+				t.Errorf("output not stripped of hidden examples: %q", rest)
+			}
+			profile, err := os.ReadFile(filepath.Join(outDir, coverFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseCoverProfile(string(profile), strings.Count(src, "\n")+1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("coverage mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -6,14 +6,11 @@ package main
 
 import (
 	"fmt"
-	"html/template"
 	"net/http"
 	"runtime"
 	"strings"
 )
 
-var editTemplate = template.Must(template.ParseFiles("edit.html"))
-
 type editData struct {
 	Snippet   *snippet
 	Share     bool
@@ -71,7 +68,7 @@ func (s *server) handleEdit(w http.ResponseWriter, r *http.Request) {
 		GoVersion: runtime.Version(),
 		Examples:  s.examples.examples,
 	}
-	if err := editTemplate.Execute(w, data); err != nil {
+	if err := s.editTemplate.Execute(w, data); err != nil {
 		s.log.Errorf("editTemplate.Execute(w, %+v): %v", data, err)
 		return
 	}
@@ -0,0 +1,121 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// annotation is an optimization decision reported by the compiler
+// for a source position, as printed with -gcflags=-m=2.
+type annotation struct {
+	File   string
+	Line   int
+	Column int
+	// Kind is one of "escapes", "moved-to-heap", "does-not-escape",
+	// "leaking-param", "can-inline", "cannot-inline" or "inlining-call".
+	Kind    string
+	Message string
+	// Detail, if non-empty, is the compiler's explanation of the
+	// data flow that caused a value to escape.
+	Detail string `json:",omitempty"`
+}
+
+// compileEscape builds the user program in req.Body with -gcflags=-m=2
+// and returns the compiler's escape analysis and inlining decisions
+// for the user's files in *response.Annotations. The program is not run.
+func compileEscape(ctx context.Context, req *request) (*response, error) {
+	tmpDir, err := ioutil.TempDir("", "sandbox")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	br, err := sandboxBuild(ctx, tmpDir, []byte(req.Body), &buildOptions{gcflags: "-m=2"})
+	if err != nil {
+		return nil, err
+	}
+	if br.errorMessage != "" {
+		return &response{Errors: br.errorMessage, CompileErrors: br.compileErrors}, nil
+	}
+	defer br.cleanup()
+	return &response{
+		IsTest:      br.testParam != "",
+		Annotations: parseAnnotations(br.output, tmpDir),
+	}, nil
+}
+
+// parseAnnotations parses the -m=2 diagnostics printed by go build
+// running in dir. Only positions in the user's files are kept. The
+// indented data flow explanation that precedes an "escapes to heap"
+// or "moved to heap" line is returned as that annotation's Detail.
+func parseAnnotations(out []byte, dir string) []annotation {
+	var (
+		anns   []annotation
+		detail = map[string][]string{} // "file:line:col" -> flow explanation
+	)
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		m := compileErrorRE.FindStringSubmatch(sc.Text())
+		if m == nil {
+			continue
+		}
+		file := relativeFile(m[1], dir)
+		if filepath.IsAbs(file) || strings.HasPrefix(file, "<") {
+			continue
+		}
+		pos, msg := m[1]+":"+m[2]+":"+m[3], m[4]
+		if strings.HasPrefix(msg, " ") || strings.HasSuffix(msg, ":") {
+			// Either a "flow:" line or the "x escapes to heap in f:"
+			// line that introduces them.
+			detail[pos] = append(detail[pos], strings.TrimSpace(msg))
+			continue
+		}
+		kind := annotationKind(msg)
+		if kind == "" {
+			continue
+		}
+		a := annotation{File: file, Kind: kind, Message: msg}
+		a.Line, _ = strconv.Atoi(m[2])
+		a.Column, _ = strconv.Atoi(m[3])
+		if kind == "escapes" || kind == "moved-to-heap" {
+			a.Detail = strings.Join(detail[pos], "\n")
+			delete(detail, pos)
+		}
+		anns = append(anns, a)
+	}
+	return anns
+}
+
+// annotationKind classifies a -m=2 diagnostic message. It returns
+// the empty string for messages that are not of interest.
+func annotationKind(msg string) string {
+	switch {
+	case strings.HasPrefix(msg, "moved to heap: "):
+		return "moved-to-heap"
+	case strings.HasSuffix(msg, " escapes to heap"):
+		return "escapes"
+	case strings.HasSuffix(msg, " does not escape"):
+		return "does-not-escape"
+	case strings.HasPrefix(msg, "leaking param"):
+		return "leaking-param"
+	case strings.HasPrefix(msg, "can inline "):
+		return "can-inline"
+	case strings.HasPrefix(msg, "cannot inline "):
+		return "cannot-inline"
+	case strings.HasPrefix(msg, "inlining call to "):
+		return "inlining-call"
+	}
+	return ""
+}
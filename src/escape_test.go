@@ -0,0 +1,42 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseAnnotations(t *testing.T) {
+	const out = `# play
+./prog.go:7:6: can inline add with cost 4 as: func(int, int) int { return a + b }
+./prog.go:11:6: cannot inline main: function too complex: cost 110 exceeds budget 80
+./prog.go:15:17: inlining call to add
+./prog.go:9:25: &T{...} escapes to heap in newT:
+./prog.go:9:25:   flow: ~r0 ← &{storage for &T{...}}:
+./prog.go:9:25:     from return &T{...} (return) at ./prog.go:9:18
+./prog.go:9:25: &T{...} escapes to heap
+./prog.go:12:2: moved to heap: x
+/tmp/sandbox1/util/util.go:3:10: leaking param: p
+./prog.go:15:13: ... argument does not escape
+/usr/local/go-faketime/src/fmt/print.go:314:6: can inline Println
+<autogenerated>:1: inlining call to T.String
+`
+	want := []annotation{
+		{File: "prog.go", Line: 7, Column: 6, Kind: "can-inline", Message: "can inline add with cost 4 as: func(int, int) int { return a + b }"},
+		{File: "prog.go", Line: 11, Column: 6, Kind: "cannot-inline", Message: "cannot inline main: function too complex: cost 110 exceeds budget 80"},
+		{File: "prog.go", Line: 15, Column: 17, Kind: "inlining-call", Message: "inlining call to add"},
+		{File: "prog.go", Line: 9, Column: 25, Kind: "escapes", Message: "&T{...} escapes to heap",
+			Detail: "&T{...} escapes to heap in newT:\nflow: ~r0 ← &{storage for &T{...}}:\nfrom return &T{...} (return) at ./prog.go:9:18"},
+		{File: "prog.go", Line: 12, Column: 2, Kind: "moved-to-heap", Message: "moved to heap: x"},
+		{File: "util/util.go", Line: 3, Column: 10, Kind: "leaking-param", Message: "leaking param: p"},
+		{File: "prog.go", Line: 15, Column: 13, Kind: "does-not-escape", Message: "... argument does not escape"},
+	}
+	got := parseAnnotations([]byte(out), "/tmp/sandbox1")
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseAnnotations mismatch (-want +got):\n%s", diff)
+	}
+}
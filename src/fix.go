@@ -0,0 +1,76 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// fixRequest is the request of the /fix endpoint: a snippet and one of
+// the suggested fixes of the diagnostics reported for it.
+type fixRequest struct {
+	Body string
+	Fix  suggestedFix
+}
+
+// handleFix applies the suggested fix of a fixRequest to its snippet
+// and returns the updated snippet, as handleFmt does.
+func (s *server) handleFix(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method == "OPTIONS" {
+		// This is likely a pre-flight CORS request.
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	var req fixRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	fs, err := splitFiles([]byte(req.Body))
+	if err != nil {
+		json.NewEncoder(w).Encode(fmtResponse{Error: err.Error()})
+		return
+	}
+	if err := applyFix(fs, &req.Fix); err != nil {
+		json.NewEncoder(w).Encode(fmtResponse{Error: err.Error()})
+		return
+	}
+	s.writeJSONResponse(w, fmtResponse{Body: string(fs.Format())}, http.StatusOK)
+}
+
+// applyFix applies the edits of fix to the files in fs. The edits of
+// a file must not overlap.
+func applyFix(fs *fileSet, fix *suggestedFix) error {
+	byFile := map[string][]textEdit{}
+	for _, e := range fix.Edits {
+		byFile[e.File] = append(byFile[e.File], e)
+	}
+	for file, edits := range byFile {
+		if !fs.Contains(file) {
+			return fmt.Errorf("%s: no such file in the snippet", file)
+		}
+		sort.SliceStable(edits, func(i, j int) bool { return edits[i].Start < edits[j].Start })
+		src := fs.Data(file)
+		var out bytes.Buffer
+		last := 0
+		for _, e := range edits {
+			if e.Start < last || e.End < e.Start || e.End > len(src) {
+				return fmt.Errorf("%s: invalid or overlapping edit of bytes %d to %d", file, e.Start, e.End)
+			}
+			out.Write(src[last:e.Start])
+			out.WriteString(e.New)
+			last = e.End
+		}
+		out.Write(src[last:])
+		fs.AddFile(file, out.Bytes())
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleFix(t *testing.T) {
+	s, err := newServer(testingOptions(t))
+	if err != nil {
+		t.Fatalf("newServer(testingOptions(t)): %v", err)
+	}
+
+	const prog = "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tx := 65\n\tfmt.Println(string(x))\n}\n"
+	for _, tt := range []struct {
+		name    string
+		body    string
+		edits   []textEdit
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "single edit",
+			body:  prog,
+			edits: []textEdit{{File: "prog.go", Start: 64, End: 70, New: "fmt.Sprint"}},
+			want:  strings.Replace(prog, "string(x)", "fmt.Sprint(x)", 1),
+		},
+		{
+			name: "unordered edits",
+			body: prog,
+			edits: []textEdit{
+				{File: "prog.go", Start: 72, End: 72, New: ")"},
+				{File: "prog.go", Start: 71, End: 71, New: "rune("},
+			},
+			want: strings.Replace(prog, "string(x)", "string(rune(x))", 1),
+		},
+		{
+			name:  "with header",
+			body:  "-- go.mod --\nmodule play\n-- sub/sub.go --\npackage sub\n\nvar X = 1\n",
+			edits: []textEdit{{File: "sub/sub.go", Start: 21, End: 22, New: "2"}},
+			want:  "-- go.mod --\nmodule play\n-- sub/sub.go --\npackage sub\n\nvar X = 2\n",
+		},
+		{
+			name:    "missing file",
+			body:    prog,
+			edits:   []textEdit{{File: "other.go", Start: 0, End: 0, New: "x"}},
+			wantErr: "other.go: no such file in the snippet",
+		},
+		{
+			name:    "out of range",
+			body:    prog,
+			edits:   []textEdit{{File: "prog.go", Start: 70, End: 1000, New: "x"}},
+			wantErr: "prog.go: invalid or overlapping edit of bytes 70 to 1000",
+		},
+		{
+			name: "overlapping",
+			body: prog,
+			edits: []textEdit{
+				{File: "prog.go", Start: 64, End: 70, New: "fmt.Sprint"},
+				{File: "prog.go", Start: 66, End: 68, New: "x"},
+			},
+			wantErr: "prog.go: invalid or overlapping edit of bytes 66 to 68",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(fixRequest{Body: tt.body, Fix: suggestedFix{Message: "fix", Edits: tt.edits}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec := httptest.NewRecorder()
+			s.handleFix(rec, httptest.NewRequest("POST", "/fix", strings.NewReader(string(body))))
+			resp := rec.Result()
+			if resp.StatusCode != 200 {
+				t.Fatalf("code = %v", resp.Status)
+			}
+			var got fmtResponse
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Body != tt.want {
+				t.Errorf("wrong output\n got: %q\nwant: %q\n", got.Body, tt.want)
+			}
+			if got.Error != tt.wantErr {
+				t.Errorf("wrong error\n got err: %q\nwant err: %q\n", got.Error, tt.wantErr)
+			}
+		})
+	}
+}
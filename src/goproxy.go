@@ -0,0 +1,307 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// localGoproxy is the URL of the module proxy served by this server,
+// if any, and localModules the paths of the modules it serves. They
+// are set in main.
+var (
+	localGoproxy string
+	localModules []string
+)
+
+// moduleProxy serves the GOPROXY protocol for the modules found in a
+// local directory, so that snippets can use third-party modules on
+// installs without access to the internet. The directory may hold a
+// pre-populated module cache (or just its cache/download directory),
+// module zips named and laid out in any way, or both.
+//
+// The directory is scanned once, when the proxy is created.
+type moduleProxy struct {
+	mods map[string]map[string]*proxyVersion // module path -> version -> files
+}
+
+// proxyVersion locates the files of a module version.
+type proxyVersion struct {
+	zip  string // path of the module zip; empty if only the go.mod is known
+	mod  string // path of the go.mod file; empty to read it from zip
+	info string // path of the .info file; empty to make one up
+	time time.Time
+}
+
+// newModuleProxy scans dir for modules.
+func newModuleProxy(dir string) (*moduleProxy, error) {
+	p := &moduleProxy{mods: map[string]map[string]*proxyVersion{}}
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if strings.Contains(d.Name(), "@") && d.Name() != "@v" {
+				// An extracted module in a module cache; its
+				// files are served from the download cache.
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Base(filepath.Dir(file)) == "@v" {
+			p.addCached(dir, file)
+		} else if filepath.Ext(file) == ".zip" {
+			p.addZip(file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// addCached adds file, a file in the @v directory of a module in a
+// module download cache in dir.
+func (p *moduleProxy) addCached(dir, file string) {
+	ext := filepath.Ext(file)
+	if ext != ".zip" && ext != ".mod" && ext != ".info" {
+		return // list, lock and ziphash files
+	}
+	rel, err := filepath.Rel(dir, filepath.Dir(filepath.Dir(file)))
+	if err != nil {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	if i := strings.Index("/"+rel, "/cache/download/"); i >= 0 {
+		// The module cache may be anywhere inside dir.
+		rel = rel[i+len("cache/download/"):]
+	}
+	mod, err := module.UnescapePath(rel)
+	if err != nil {
+		return
+	}
+	version, err := module.UnescapeVersion(strings.TrimSuffix(filepath.Base(file), ext))
+	if err != nil {
+		return
+	}
+	v := p.version(mod, version)
+	switch ext {
+	case ".zip":
+		v.zip = file
+	case ".mod":
+		v.mod = file
+	case ".info":
+		v.info = file
+	}
+	if fi, err := os.Stat(file); err == nil && fi.ModTime().After(v.time) {
+		v.time = fi.ModTime()
+	}
+}
+
+// addZip adds file if it is a module zip, whose files are all in a
+// module@version directory.
+func (p *moduleProxy) addZip(file string) {
+	zr, err := zip.OpenReader(file)
+	if err != nil || len(zr.File) == 0 {
+		return
+	}
+	defer zr.Close()
+	// Module paths cannot contain "@", and versions cannot contain "/".
+	mod, rest, ok := strings.Cut(zr.File[0].Name, "@")
+	if !ok {
+		return
+	}
+	version, _, ok := strings.Cut(rest, "/")
+	if !ok || module.Check(mod, version) != nil {
+		return
+	}
+	v := p.version(mod, version)
+	v.zip = file
+	if fi, err := os.Stat(file); err == nil {
+		v.time = fi.ModTime()
+	}
+}
+
+func (p *moduleProxy) version(mod, version string) *proxyVersion {
+	vs := p.mods[mod]
+	if vs == nil {
+		vs = map[string]*proxyVersion{}
+		p.mods[mod] = vs
+	}
+	v := vs[version]
+	if v == nil {
+		v = new(proxyVersion)
+		vs[version] = v
+	}
+	return v
+}
+
+// empty reports whether no module was found.
+func (p *moduleProxy) empty() bool {
+	return len(p.mods) == 0
+}
+
+// paths returns the sorted paths of the modules served by p.
+func (p *moduleProxy) paths() []string {
+	var paths []string
+	for mod := range p.mods {
+		paths = append(paths, mod)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// serve serves the GOPROXY protocol on a listener of the loopback
+// interface, for the go commands run by this server only, and returns
+// its URL. It is never served on the public listener, where a reverse
+// proxy on the same host would make every client look local.
+func (p *moduleProxy) serve() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	go func() {
+		log.Fatalf("Error serving the module proxy: %v", http.Serve(l, p))
+	}()
+	return "http://" + l.Addr().String(), nil
+}
+
+// ServeHTTP serves the GOPROXY protocol.
+func (p *moduleProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	escMod, file, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/@v/")
+	if !ok {
+		if strings.HasSuffix(r.URL.Path, "/@latest") {
+			escMod, file = strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/@latest"), "@latest"
+		} else {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	mod, err := module.UnescapePath(escMod)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	vs := p.mods[mod]
+	if vs == nil {
+		// Not found lets the go command try the next proxy.
+		http.Error(w, fmt.Sprintf("not found: module %s", mod), http.StatusNotFound)
+		return
+	}
+
+	switch file {
+	case "list":
+		var list []string
+		for version, v := range vs {
+			if v.zip != "" && !module.IsPseudoVersion(version) {
+				list = append(list, version)
+			}
+		}
+		semver.Sort(list)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, version := range list {
+			fmt.Fprintln(w, version)
+		}
+		return
+	case "@latest":
+		latest := ""
+		for version, v := range vs {
+			if v.zip != "" && semver.Compare(version, latest) > 0 {
+				latest = version
+			}
+		}
+		if latest == "" {
+			http.Error(w, fmt.Sprintf("not found: %s@latest", mod), http.StatusNotFound)
+			return
+		}
+		p.serveInfo(w, latest, vs[latest])
+		return
+	}
+
+	ext := path.Ext(file)
+	version, err := module.UnescapeVersion(strings.TrimSuffix(file, ext))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	v := vs[version]
+	if v == nil {
+		http.Error(w, fmt.Sprintf("not found: %s@%s", mod, version), http.StatusNotFound)
+		return
+	}
+	switch {
+	case ext == ".info":
+		p.serveInfo(w, version, v)
+	case ext == ".mod" && v.mod != "":
+		http.ServeFile(w, r, v.mod)
+	case ext == ".mod" && v.zip != "":
+		p.serveZippedMod(w, mod, version, v.zip)
+	case ext == ".zip" && v.zip != "":
+		w.Header().Set("Content-Type", "application/zip")
+		http.ServeFile(w, r, v.zip)
+	default:
+		http.Error(w, fmt.Sprintf("not found: %s@%s%s", mod, version, ext), http.StatusNotFound)
+	}
+}
+
+// serveInfo serves the .info file of version v, making one up from
+// the time of its files if the directory has none.
+func (p *moduleProxy) serveInfo(w http.ResponseWriter, version string, v *proxyVersion) {
+	if v.info != "" {
+		if b, err := os.ReadFile(v.info); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Version string
+		Time    time.Time
+	}{version, v.time.UTC().Truncate(time.Second)})
+}
+
+// serveZippedMod serves the go.mod file in the module zip, or a
+// synthesized one for modules that have none.
+func (p *moduleProxy) serveZippedMod(w http.ResponseWriter, mod, version, file string) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer zr.Close()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	name := mod + "@" + version + "/go.mod"
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rc.Close()
+		io.Copy(w, rc)
+		return
+	}
+	fmt.Fprintf(w, "module %s\n", mod)
+}
@@ -0,0 +1,109 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestModuleProxy(t *testing.T) {
+	dir := t.TempDir()
+	writeZip := func(name string, files map[string]string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zw := zip.NewWriter(f)
+		for name, data := range files {
+			w, err := zw.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte(data))
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Loose module zips, one of them without a go.mod.
+	writeZip(filepath.Join(dir, "zips", "a.zip"), map[string]string{
+		"example.com/a@v1.0.0/go.mod": "module example.com/a\n",
+		"example.com/a@v1.0.0/a.go":   "package a\n",
+	})
+	writeZip(filepath.Join(dir, "zips", "a2.zip"), map[string]string{
+		"example.com/a@v1.1.0/a.go": "package a\n",
+	})
+	writeZip(filepath.Join(dir, "zips", "notamodule.zip"), map[string]string{
+		"README": "hello",
+	})
+	// A module cache, with an uppercase module path.
+	dl := filepath.Join(dir, "pkg", "mod", "cache", "download", "example.com", "!b", "@v")
+	write(filepath.Join(dl, "list"), "v0.1.0\n")
+	write(filepath.Join(dl, "v0.1.0.info"), `{"Version":"v0.1.0","Time":"2020-01-02T03:04:05Z"}`)
+	write(filepath.Join(dl, "v0.1.0.mod"), "module example.com/B\n")
+	writeZip(filepath.Join(dl, "v0.1.0.zip"), map[string]string{"example.com/!b@v0.1.0/b.go": "package b\n"})
+	write(filepath.Join(dl, "v0.2.0.mod"), "module example.com/B\n") // no zip
+	writeZip(filepath.Join(dir, "pkg", "mod", "example.com", "!b@v0.1.0", "testdata", "x.zip"), map[string]string{
+		"example.com/c@v1.0.0/go.mod": "module example.com/c\n",
+	})
+
+	p, err := newModuleProxy(dir)
+	if err != nil {
+		t.Fatalf("newModuleProxy: %v", err)
+	}
+
+	for _, tc := range []struct {
+		path     string
+		code     int
+		contains string
+	}{
+		{"/example.com/a/@v/list", http.StatusOK, "v1.0.0\nv1.1.0\n"},
+		{"/example.com/a/@v/v1.0.0.info", http.StatusOK, `"Version":"v1.0.0"`},
+		{"/example.com/a/@v/v1.0.0.mod", http.StatusOK, "module example.com/a\n"},
+		{"/example.com/a/@v/v1.1.0.mod", http.StatusOK, "module example.com/a\n"},
+		{"/example.com/a/@v/v1.0.0.zip", http.StatusOK, "PK"},
+		{"/example.com/a/@latest", http.StatusOK, `"Version":"v1.1.0"`},
+		{"/example.com/a/@v/v2.0.0.info", http.StatusNotFound, "not found"},
+		{"/example.com/!b/@v/list", http.StatusOK, "v0.1.0\n"},
+		{"/example.com/!b/@v/v0.1.0.info", http.StatusOK, `"Time":"2020-01-02T03:04:05Z"`},
+		{"/example.com/!b/@v/v0.2.0.mod", http.StatusOK, "module example.com/B\n"},
+		{"/example.com/!b/@v/v0.2.0.zip", http.StatusNotFound, "not found"},
+		{"/example.com/c/@v/list", http.StatusNotFound, "not found"},
+		{"/example.com/unknown/@v/list", http.StatusNotFound, "not found"},
+	} {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+		if w.Code != tc.code || !strings.Contains(w.Body.String(), tc.contains) {
+			t.Errorf("GET %s = %d %q; want %d containing %q", tc.path, w.Code, w.Body, tc.code, tc.contains)
+		}
+	}
+
+	want := []string{"example.com/B", "example.com/a"}
+	if got := p.paths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("paths() = %q; want %q", got, want)
+	}
+}
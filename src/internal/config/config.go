@@ -0,0 +1,305 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package config is the configuration of the playground's web and
+// sandbox servers.
+//
+// Both servers read the same YAML file, each its own section of it.
+// Settings are taken, from lowest to highest precedence, from the
+// defaults documented in Default, the configuration file, environment
+// variables and command-line flags. See playground.example.yaml at the
+// root of the repository for a complete file.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of the playground.
+//
+// Each setting has a yaml key in the file, and may have an environment
+// variable (env) and a command-line flag (flag) overriding it.
+type Config struct {
+	Web     Web     `yaml:"web"`
+	Sandbox Sandbox `yaml:"sandbox"`
+}
+
+// Web is the configuration of the web server.
+type Web struct {
+	// Port is the port to listen on.
+	Port string `yaml:"port" env:"PORT"`
+	// MemcachedAddr is the address of the memcached server caching
+	// results; empty disables caching.
+	MemcachedAddr string `yaml:"memcached_addr" env:"MEMCACHED_ADDR"`
+	// BackendURL is the URL of the sandbox's /run endpoint.
+	BackendURL string `yaml:"backend_url" env:"SANDBOX_BACKEND_URL" flag:"backend-url"`
+
+	// Goproxy and Gosumdb are the GOPROXY and GOSUMDB of builds;
+	// empty selects the defaults described in playgroundGoproxy and
+	// playgroundGosumdb.
+	Goproxy string `yaml:"goproxy" env:"PLAY_GOPROXY"`
+	Gosumdb string `yaml:"gosumdb" env:"PLAY_GOSUMDB"`
+	// GoproxyFallback is the GOPROXY list tried for the modules that
+	// the module proxy serving ModuleDir does not have; empty uses
+	// that proxy only.
+	GoproxyFallback string `yaml:"goproxy_fallback" env:"PLAY_GOPROXY_FALLBACK"`
+	// GoPrivate, GoNoProxy and GoNoSumDB are passed to builds as
+	// GOPRIVATE, GONOPROXY and GONOSUMDB.
+	GoPrivate string `yaml:"goprivate" env:"GOPRIVATE"`
+	GoNoProxy string `yaml:"gonoproxy" env:"GONOPROXY"`
+	GoNoSumDB string `yaml:"gonosumdb" env:"GONOSUMDB"`
+
+	// MaxBuildTime bounds the time to download modules and build a
+	// program, and MaxRunTime the time to run it, which should be
+	// more than the sandbox's RunTimeout.
+	MaxBuildTime time.Duration `yaml:"max_build_time" env:"PLAY_MAX_BUILD_TIME"`
+	MaxRunTime   time.Duration `yaml:"max_run_time" env:"PLAY_MAX_RUN_TIME"`
+	// MaxSnippetSize is the maximum size in bytes of a shared
+	// snippet, not counting vendored files.
+	MaxSnippetSize int `yaml:"max_snippet_size" env:"PLAY_MAX_SNIPPET_SIZE"`
+	// MaxFiles is the maximum number of files in a snippet, not
+	// counting vendored files, which are limited by MaxVendorFiles
+	// and MaxVendorSize (in bytes).
+	MaxFiles       int `yaml:"max_files" env:"PLAY_MAX_FILES"`
+	MaxVendorFiles int `yaml:"max_vendor_files" env:"PLAY_MAX_VENDOR_FILES"`
+	MaxVendorSize  int `yaml:"max_vendor_size" env:"PLAY_MAX_VENDOR_SIZE"`
+
+	BuildWorkers int `yaml:"build_workers" env:"PLAY_BUILD_WORKERS" flag:"build-workers"`
+	BuildQueue   int `yaml:"build_queue" env:"PLAY_BUILD_QUEUE" flag:"build-queue"`
+
+	RateLimits         string `yaml:"rate_limits" env:"PLAY_RATE_LIMITS" flag:"rate-limits"`
+	TrustedProxyHeader string `yaml:"trusted_proxy_header" env:"PLAY_TRUSTED_PROXY_HEADER" flag:"trusted-proxy-header"`
+	APITokensFile      string `yaml:"api_tokens_file" env:"PLAY_API_TOKENS_FILE" flag:"api-tokens-file"`
+
+	CacheDir     string `yaml:"cache_dir" env:"PLAY_CACHE_DIR" flag:"cache-dir"`
+	BuildCacheMB int64  `yaml:"build_cache_mb" env:"PLAY_BUILD_CACHE_MB" flag:"build-cache-mb"`
+	ModCacheMB   int64  `yaml:"mod_cache_mb" env:"PLAY_MOD_CACHE_MB" flag:"mod-cache-mb"`
+
+	ModuleDir   string `yaml:"module_dir" env:"PLAY_MODULE_DIR" flag:"module-dir"`
+	ModuleAllow string `yaml:"module_allow" env:"PLAY_MODULE_ALLOW" flag:"module-allow"`
+	ModuleDeny  string `yaml:"module_deny" env:"PLAY_MODULE_DENY" flag:"module-deny"`
+
+	// Analyzers is the comma-separated list of the analyzers beyond
+	// go vet's that requests may select.
+	Analyzers string `yaml:"analyzers" env:"PLAY_ANALYZERS" flag:"analyzers"`
+	// VulnDB is the directory of a local mirror of the Go
+	// vulnerability database that snippets are checked against;
+	// empty disables vulnerability checking.
+	VulnDB string `yaml:"vuln_db" env:"PLAY_VULN_DB" flag:"vuln-db"`
+
+	// MetricsPeriod is how often the metrics of the server are
+	// written to its log; zero disables them.
+	MetricsPeriod time.Duration `yaml:"metrics_period" env:"PLAY_METRICS_PERIOD" flag:"metrics-period"`
+}
+
+// Sandbox is the configuration of the sandbox server.
+type Sandbox struct {
+	// Listen is the HTTP server listen address.
+	Listen string `yaml:"listen" env:"SANDBOX_LISTEN" flag:"listen"`
+	// Workers is the number of containers run at once.
+	Workers int `yaml:"workers" env:"SANDBOX_WORKERS" flag:"workers"`
+	// UntrustedContainer is the image of the containers that run
+	// programs.
+	UntrustedContainer string `yaml:"untrusted_container" env:"SANDBOX_UNTRUSTED_CONTAINER" flag:"untrusted-container"`
+	// Dev runs the server in development mode.
+	Dev bool `yaml:"dev" env:"SANDBOX_DEV" flag:"dev"`
+
+	// RunTimeout bounds the time a program runs.
+	RunTimeout time.Duration `yaml:"run_timeout" env:"SANDBOX_RUN_TIMEOUT" flag:"run-timeout"`
+	// MemoryLimitBytes is the memory limit of a container.
+	MemoryLimitBytes int64 `yaml:"memory_limit_bytes" env:"SANDBOX_MEMORY_LIMIT_BYTES"`
+	// MaxOutputSize bounds the output of a program, and
+	// MaxBinarySize the size of the programs it accepts, in bytes.
+	MaxOutputSize int64 `yaml:"max_output_size" env:"SANDBOX_MAX_OUTPUT_SIZE"`
+	MaxBinarySize int64 `yaml:"max_binary_size" env:"SANDBOX_MAX_BINARY_SIZE"`
+}
+
+// Default returns the default configuration.
+func Default() *Config {
+	return &Config{
+		Web: Web{
+			Port:           "8080",
+			MaxBuildTime:   10 * time.Second,
+			MaxRunTime:     5 * time.Second,
+			MaxSnippetSize: 64 * 1024,
+			MaxFiles:       20,
+			MaxVendorFiles: 500,
+			MaxVendorSize:  1 << 20,
+			BuildWorkers:   runtime.NumCPU(),
+			BuildQueue:     10 * runtime.NumCPU(),
+			CacheDir:       filepath.Join(os.TempDir(), "playground-cache"),
+			BuildCacheMB:   2048,
+			ModCacheMB:     4096,
+			ModuleDir:      "modules",
+			Analyzers:      "nilness,shadow,unusedwrite",
+		},
+		Sandbox: Sandbox{
+			Listen:             ":80",
+			Workers:            runtime.NumCPU(),
+			UntrustedContainer: "gcr.io/golang-org/playground-sandbox-gvisor:latest",
+			RunTimeout:         5 * time.Second,
+			MemoryLimitBytes:   100 << 20,
+			MaxOutputSize:      100 << 20,
+			MaxBinarySize:      100 << 20,
+		},
+	}
+}
+
+// Load returns the configuration from the YAML file, if not empty, the
+// environment and the flags set in fs, if not nil. The flags are those
+// named by the flag tags of Config; other flags are ignored.
+func Load(file string, fs *flag.FlagSet) (*Config, error) {
+	c := Default()
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+	}
+	if err := c.override("env", os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if fs != nil {
+		set := map[string]string{}
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = f.Value.String() })
+		if err := c.override("flag", func(name string) (string, bool) {
+			v, ok := set[name]
+			return v, ok
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// override sets the settings with a tag of the given kind, env or
+// flag, for which lookup returns a value. Empty environment variables
+// are ignored, as if unset.
+func (c *Config) override(kind string, lookup func(name string) (string, bool)) error {
+	for _, section := range []reflect.Value{reflect.ValueOf(&c.Web).Elem(), reflect.ValueOf(&c.Sandbox).Elem()} {
+		t := section.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := t.Field(i).Tag.Get(kind)
+			if name == "" {
+				continue
+			}
+			v, ok := lookup(name)
+			if !ok || (kind == "env" && v == "") {
+				continue
+			}
+			if err := set(section.Field(i), v); err != nil {
+				if kind == "flag" {
+					return fmt.Errorf("flag -%s: %v", name, err)
+				}
+				return fmt.Errorf("$%s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// set parses s into the setting v.
+func set(v reflect.Value, s string) error {
+	switch v.Interface().(type) {
+	case string:
+		v.SetString(s)
+	case bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case int, int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	default:
+		panic(fmt.Sprintf("config: unsupported setting type %s", v.Type()))
+	}
+	return nil
+}
+
+// Validate reports the first invalid setting in c, if any.
+func (c *Config) Validate() error {
+	w, s := &c.Web, &c.Sandbox
+	if _, err := strconv.ParseUint(w.Port, 10, 16); err != nil {
+		return fmt.Errorf("web.port: invalid port %q", w.Port)
+	}
+	if w.BackendURL != "" {
+		if u, err := url.Parse(w.BackendURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("web.backend_url: invalid URL %q", w.BackendURL)
+		}
+	}
+	for _, d := range []struct {
+		key string
+		v   time.Duration
+	}{
+		{"web.max_build_time", w.MaxBuildTime},
+		{"web.max_run_time", w.MaxRunTime},
+		{"sandbox.run_timeout", s.RunTimeout},
+	} {
+		if d.v <= 0 {
+			return fmt.Errorf("%s: must be positive, not %v", d.key, d.v)
+		}
+	}
+	for _, n := range []struct {
+		key string
+		v   int64
+	}{
+		{"web.max_snippet_size", int64(w.MaxSnippetSize)},
+		{"web.max_files", int64(w.MaxFiles)},
+		{"web.max_vendor_files", int64(w.MaxVendorFiles)},
+		{"web.max_vendor_size", int64(w.MaxVendorSize)},
+		{"web.build_workers", int64(w.BuildWorkers)},
+		{"web.build_cache_mb", w.BuildCacheMB},
+		{"web.mod_cache_mb", w.ModCacheMB},
+		{"sandbox.workers", int64(s.Workers)},
+		{"sandbox.memory_limit_bytes", s.MemoryLimitBytes},
+		{"sandbox.max_output_size", s.MaxOutputSize},
+		{"sandbox.max_binary_size", s.MaxBinarySize},
+	} {
+		if n.v <= 0 {
+			return fmt.Errorf("%s: must be positive, not %d", n.key, n.v)
+		}
+	}
+	if w.BuildQueue < 0 {
+		return fmt.Errorf("web.build_queue: must not be negative, not %d", w.BuildQueue)
+	}
+	if w.MetricsPeriod < 0 {
+		return fmt.Errorf("web.metrics_period: must not be negative, not %v", w.MetricsPeriod)
+	}
+	if s.UntrustedContainer == "" {
+		return errors.New("sandbox.untrusted_container: must not be empty")
+	}
+	return nil
+}
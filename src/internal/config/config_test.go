@@ -0,0 +1,171 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "playground.yaml")
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// clearEnv unsets the environment variables of the settings for the
+// duration of the test.
+func clearEnv(t *testing.T) {
+	for _, typ := range []reflect.Type{reflect.TypeOf(Web{}), reflect.TypeOf(Sandbox{})} {
+		for i := 0; i < typ.NumField(); i++ {
+			if name := typ.Field(i).Tag.Get("env"); name != "" {
+				t.Setenv(name, "") // ignored, as if unset
+			}
+		}
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	file := writeConfig(t, `
+web:
+  port: "9000"
+  max_build_time: 30s
+  build_workers: 2
+  build_queue: 4
+  goproxy: https://file.example
+sandbox:
+  workers: 3
+  run_timeout: 8s
+`)
+	t.Setenv("PLAY_BUILD_WORKERS", "5")
+	t.Setenv("PLAY_GOPROXY", "https://env.example")
+	t.Setenv("SANDBOX_RUN_TIMEOUT", "") // ignored
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("build-workers", 1, "")
+	fs.Int("build-queue", 1, "") // not set: its default is ignored
+	fs.Duration("run-timeout", time.Second, "")
+	if err := fs.Parse([]string{"-build-workers=7", "-run-timeout=9s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(file, fs)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := Default()
+	want.Web.Port = "9000"                    // file
+	want.Web.MaxBuildTime = 30 * time.Second  // file
+	want.Web.BuildWorkers = 7                 // flag over env over file
+	want.Web.BuildQueue = 4                   // file
+	want.Web.Goproxy = "https://env.example"  // env over file
+	want.Sandbox.Workers = 3                  // file
+	want.Sandbox.RunTimeout = 9 * time.Second // flag over file
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Load mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	clearEnv(t)
+	for _, file := range []string{"", writeConfig(t, ""), writeConfig(t, "# nothing here\n")} {
+		got, err := Load(file, nil)
+		if err != nil {
+			t.Fatalf("Load(%q): %v", file, err)
+		}
+		if diff := cmp.Diff(Default(), got); diff != "" {
+			t.Errorf("Load(%q) mismatch (-want +got):\n%s", file, diff)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		config  string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "unknown key",
+			config:  "web:\n  max_run_tim: 5s\n",
+			wantErr: "field max_run_tim not found",
+		},
+		{
+			name:    "bad type",
+			config:  "web:\n  max_files: many\n",
+			wantErr: "cannot unmarshal",
+		},
+		{
+			name:    "bad env",
+			env:     map[string]string{"PLAY_MAX_RUN_TIME": "5"},
+			wantErr: "$PLAY_MAX_RUN_TIME: time: missing unit",
+		},
+		{
+			name:    "bad port",
+			config:  "web:\n  port: http\n",
+			wantErr: `web.port: invalid port "http"`,
+		},
+		{
+			name:    "bad backend url",
+			env:     map[string]string{"SANDBOX_BACKEND_URL": "sandbox/run"},
+			wantErr: `web.backend_url: invalid URL "sandbox/run"`,
+		},
+		{
+			name:    "negative duration",
+			config:  "sandbox:\n  run_timeout: -1s\n",
+			wantErr: "sandbox.run_timeout: must be positive, not -1s",
+		},
+		{
+			name:    "zero size",
+			config:  "web:\n  max_snippet_size: 0\n",
+			wantErr: "web.max_snippet_size: must be positive, not 0",
+		},
+		{
+			name:    "negative queue",
+			config:  "web:\n  build_queue: -1\n",
+			wantErr: "web.build_queue: must not be negative, not -1",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			file := ""
+			if tt.config != "" {
+				file = writeConfig(t, tt.config)
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := Load(file, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v; want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestExampleFile checks that the example configuration file at the
+// root of the repository documents the defaults.
+func TestExampleFile(t *testing.T) {
+	clearEnv(t)
+	got, err := Load(filepath.Join("..", "..", "..", "playground.example.yaml"), nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if diff := cmp.Diff(Default(), got); diff != "" {
+		t.Errorf("example file mismatch (-defaults +example):\n%s", diff)
+	}
+}
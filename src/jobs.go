@@ -0,0 +1,399 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+const (
+	// finishedJobTTL is how long the result of a finished job is
+	// kept for clients to fetch.
+	finishedJobTTL = 10 * time.Minute
+	// maxJobs bounds the number of jobs, running or finished, kept
+	// in memory at once.
+	maxJobs = 1000
+	// maxJobOutput bounds the size of the output kept for a job,
+	// streamed or in its result, and maxJobsOutput that of the
+	// output kept for all jobs together. Output beyond them is
+	// dropped.
+	maxJobOutput  = 1 << 20
+	maxJobsOutput = 64 << 20
+)
+
+// Job states, as reported in jobStatus.Status.
+const (
+	jobQueued   = "queued"
+	jobBuilding = "building"
+	jobRunning  = "running"
+	jobDone     = "done"
+	jobCanceled = "canceled"
+	jobFailed   = "failed"
+)
+
+var errTooManyJobs = errors.New("too many jobs")
+
+// jobStatus is the JSON representation of a job in the /jobs API.
+type jobStatus struct {
+	Id     string
+	Status string // one of the job states above
+	// QueuePosition is the 1-based position of the job in the build
+	// queue while Status is "queued".
+	QueuePosition int `json:",omitempty"`
+	// Offset is the index of the first of Events in the program's
+	// output. Clients poll with ?offset=N to only get new output.
+	Offset int
+	// Events is the program's output so far. Once the job is done,
+	// the complete output is in Result instead.
+	Events []Event `json:",omitempty"`
+	// Result is the /compile response, once Status is "done".
+	Result *response `json:",omitempty"`
+	// Error explains why a job failed, if it is worth retrying.
+	Error string `json:",omitempty"`
+	// Truncated reports whether output was dropped from Events or
+	// Result, beyond maxJobOutput or maxJobsOutput.
+	Truncated bool `json:",omitempty"`
+}
+
+// A job is a program built and run in the background for the /jobs API,
+// so that clients do not have to keep a request open for the whole
+// build and run.
+type job struct {
+	id     string
+	cancel context.CancelFunc
+	store  *jobStore
+
+	mu        sync.Mutex
+	status    string
+	position  int       // position in the build queue, if status is jobQueued
+	errMsg    string    // jobStatus.Error
+	events    []Event   // output so far
+	result    *response // set once status is jobDone
+	size      int64     // size of the output in events or result
+	truncated bool      // jobStatus.Truncated
+	finished  time.Time // zero while the job is building or running
+}
+
+// snapshot returns the status of j, with the output from offset on.
+func (j *job) snapshot(offset int) *jobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	st := &jobStatus{Id: j.id, Status: j.status, Result: j.result, Error: j.errMsg, Truncated: j.truncated}
+	if j.status == jobQueued {
+		st.QueuePosition = j.position
+	}
+	if j.result == nil {
+		if offset > len(j.events) {
+			offset = len(j.events)
+		}
+		st.Offset = offset
+		st.Events = append([]Event(nil), j.events[offset:]...)
+	}
+	return st
+}
+
+func (j *job) setStatus(status string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.finished.IsZero() {
+		j.status = status
+	}
+}
+
+// setQueued records the position of j in the build queue, 0 meaning
+// it has left the queue and is building.
+func (j *job) setQueued(position int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.finished.IsZero() {
+		return
+	}
+	j.status, j.position = jobQueued, position
+	if position == 0 {
+		j.status = jobBuilding
+	}
+}
+
+func (j *job) addEvent(e Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.finished.IsZero() {
+		return
+	}
+	if !j.keep(len(e.Message)) {
+		j.truncated = true
+		return
+	}
+	j.events = append(j.events, e)
+}
+
+// keep reports whether n more bytes of output may be kept for j, and
+// if so accounts for them. j.mu must be held.
+func (j *job) keep(n int) bool {
+	if j.size+int64(n) > maxJobOutput || !j.store.reserve(int64(n)) {
+		return false
+	}
+	j.size += int64(n)
+	return true
+}
+
+// release stops accounting for the output kept for j. j.mu must be held.
+func (j *job) release() {
+	j.store.size.Add(-j.size)
+	j.size = 0
+}
+
+// finish records the outcome of j, unless it already finished.
+func (j *job) finish(status string, result *response, now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.finished.IsZero() {
+		return
+	}
+	j.status, j.finished = status, now
+	if result == nil {
+		return
+	}
+	// The complete output is in result.
+	j.events = nil
+	j.release()
+	for i, e := range result.Events {
+		if !j.keep(len(e.Message)) {
+			// result may be cached or shared; truncate a copy.
+			r := *result
+			r.Events = r.Events[:i:i]
+			result = &r
+			j.truncated = true
+			break
+		}
+	}
+	j.result = result
+}
+
+// fail marks j as failed with a message for the client.
+func (j *job) fail(msg string, now time.Time) {
+	j.mu.Lock()
+	if j.finished.IsZero() {
+		j.errMsg = msg
+	}
+	j.mu.Unlock()
+	j.finish(jobFailed, nil, now)
+}
+
+// jobStore holds the jobs of the /jobs API. Finished jobs are removed
+// finishedJobTTL after they finish.
+type jobStore struct {
+	// run builds and runs a program for a job.
+	run func(context.Context, *request, *runObserver) (*response, error)
+	now func() time.Time
+	// size is the size of the output kept for all jobs.
+	size atomic.Int64
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+func newJobStore() *jobStore {
+	return &jobStore{
+		run:  compileAndRunObserved,
+		now:  time.Now,
+		jobs: map[string]*job{},
+	}
+}
+
+// reserve reports whether n more bytes of output may be kept for the
+// jobs, and if so accounts for them.
+func (js *jobStore) reserve(n int64) bool {
+	if js.size.Add(n) > maxJobsOutput {
+		js.size.Add(-n)
+		return false
+	}
+	return true
+}
+
+// expired reports whether j finished more than finishedJobTTL ago,
+// and if so stops accounting for its output.
+func (js *jobStore) expired(j *job, now time.Time) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.finished.IsZero() || now.Sub(j.finished) <= finishedJobTTL {
+		return false
+	}
+	j.release()
+	return true
+}
+
+// add registers a new job, removing expired jobs first.
+func (js *jobStore) add() (*job, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	now := js.now()
+	for id, j := range js.jobs {
+		if js.expired(j, now) {
+			delete(js.jobs, id)
+		}
+	}
+	if len(js.jobs) >= maxJobs {
+		return nil, errTooManyJobs
+	}
+	j := &job{id: hex.EncodeToString(b[:]), store: js, status: jobBuilding}
+	js.jobs[j.id] = j
+	return j, nil
+}
+
+// get returns the job with the given id, or nil if there is none or
+// it has expired.
+func (js *jobStore) get(id string) *job {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	j := js.jobs[id]
+	if j == nil {
+		return nil
+	}
+	if js.expired(j, js.now()) {
+		delete(js.jobs, id)
+		return nil
+	}
+	return j
+}
+
+// handleJobs serves the /jobs API, an asynchronous alternative to
+// /compile:
+//
+//	POST /jobs          starts a job for a /compile request and returns its status
+//	GET /jobs/{id}      returns the status and output so far of a job
+//	DELETE /jobs/{id}   cancels a job
+//
+// Like /compile, the API supports CORS from any domain.
+func (s *server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE")
+	if r.Method == "OPTIONS" {
+		// This is likely a pre-flight CORS request.
+		return
+	}
+
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/jobs"), "/")
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		s.startJob(w, r)
+		return
+	case id == "":
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	j := s.jobs.get(id)
+	if j == nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		offset, _ := strconv.Atoi(r.FormValue("offset"))
+		if offset < 0 {
+			offset = 0
+		}
+		s.writeJSONResponse(w, j.snapshot(offset), http.StatusOK)
+	case http.MethodDelete:
+		j.cancel()
+		j.finish(jobCanceled, nil, s.jobs.now())
+		s.writeJSONResponse(w, j.snapshot(0), http.StatusOK)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// startJob handles POST /jobs. A cached /compile result finishes the
+// job immediately; otherwise the program is built and run in the
+// background, and its result is cached as by /compile.
+func (s *server) startJob(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeRequest(r)
+	if err != nil {
+		s.log.Errorf("error decoding request: %v", err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	j, err := s.jobs.add()
+	if err != nil {
+		s.log.Errorf("error adding job: %v", err)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+
+	cachePrefix := "prog"
+	if req.WithVet {
+		cachePrefix += "_vet"
+	}
+	key := cacheKey(cachePrefix, req.cacheBody())
+	resp := &response{}
+	if err := s.cache.Get(key, resp); err == nil {
+		cancel()
+		j.finish(jobDone, resp, s.jobs.now())
+	} else {
+		if !errors.Is(err, memcache.ErrCacheMiss) {
+			s.log.Errorf("s.cache.Get(%q, &response): %v", key, err)
+		}
+		go s.runJob(ctx, j, req, key)
+	}
+
+	w.Header().Set("Location", "/jobs/"+j.id)
+	s.writeJSONResponse(w, j.snapshot(0), http.StatusAccepted)
+}
+
+// runJob builds and runs the program for j.
+func (s *server) runJob(ctx context.Context, j *job, req *request, key string) {
+	defer j.cancel()
+	obs := &runObserver{
+		status: func(stage string) {
+			if stage == "running" {
+				j.setStatus(jobRunning)
+			}
+		},
+		queued: j.setQueued,
+		event:  j.addEvent,
+	}
+	resp, err := s.jobs.run(ctx, req, obs)
+	if ctx.Err() != nil {
+		j.finish(jobCanceled, nil, s.jobs.now())
+		return
+	}
+	if errors.Is(err, errBuildQueueFull) {
+		j.fail("too many programs are being built; try again later", s.jobs.now())
+		return
+	}
+	if err != nil {
+		s.log.Errorf("job %s: %v", j.id, err)
+		j.finish(jobFailed, nil, s.jobs.now())
+		return
+	}
+	cacheable, err := s.checkResponse(resp)
+	if err != nil {
+		j.finish(jobFailed, nil, s.jobs.now())
+		return
+	}
+	if cacheable {
+		if err := s.cache.Set(key, resp); err != nil {
+			s.log.Errorf("cache.Set(%q, resp): %v", key, err)
+		}
+	}
+	j.finish(jobDone, resp, s.jobs.now())
+}
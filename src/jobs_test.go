@@ -0,0 +1,190 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJobs(t *testing.T) {
+	s, err := newServer(func(s *server) error {
+		s.db = &inMemStore{}
+		s.log = newStdLogger()
+		s.cache = new(inMemCache)
+		var err error
+		s.examples, err = newExamplesHandler(time.Now())
+		return err
+	})
+	if err != nil {
+		t.Fatalf("newServer: %v", err)
+	}
+	var (
+		mu  sync.Mutex
+		now = time.Now()
+	)
+	s.jobs.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	canceled := make(chan bool, 1)
+	s.jobs.run = func(ctx context.Context, req *request, obs *runObserver) (*response, error) {
+		obs.setStatus("running")
+		obs.event(Event{"partial", "stdout", 0})
+		if req.Body == "wait" {
+			<-ctx.Done()
+			canceled <- true
+			return nil, ctx.Err()
+		}
+		return &response{Events: []Event{{req.Body, "stdout", 0}}}, nil
+	}
+
+	do := func(method, path, body string, wantCode int) *jobStatus {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if w.Code != wantCode {
+			t.Fatalf("%s %s: got status %d, want %d", method, path, w.Code, wantCode)
+		}
+		if w.Code >= 300 {
+			return nil
+		}
+		st := new(jobStatus)
+		if err := json.NewDecoder(w.Body).Decode(st); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+		return st
+	}
+	// poll waits for the job to leave the "building" state, or to
+	// reach state want if it is not "running".
+	poll := func(id, query, want string) *jobStatus {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			st := do("GET", "/jobs/"+id+query, "", http.StatusOK)
+			if st.Status == want || want == jobRunning && st.Status != jobBuilding {
+				return st
+			}
+		}
+		t.Fatalf("job %s did not reach status %q", id, want)
+		return nil
+	}
+
+	// A job that completes.
+	st := do("POST", "/jobs", `{"Body":"ok"}`, http.StatusAccepted)
+	st = poll(st.Id, "", jobDone)
+	want := &jobStatus{Id: st.Id, Status: jobDone, Result: &response{Events: []Event{{"ok", "stdout", 0}}}}
+	if diff := cmp.Diff(want, st); diff != "" {
+		t.Errorf("finished job mismatch (-want +got):\n%s", diff)
+	}
+
+	// Its result is cached: the same program finishes immediately.
+	st2 := do("POST", "/jobs", `{"Body":"ok"}`, http.StatusAccepted)
+	if st2.Status != jobDone || st2.Id == st.Id {
+		t.Errorf("cached job: got %+v, want new done job", st2)
+	}
+
+	// A job that runs until it is canceled.
+	st = do("POST", "/jobs", `{"Body":"wait"}`, http.StatusAccepted)
+	st = poll(st.Id, "", jobRunning)
+	if diff := cmp.Diff([]Event{{"partial", "stdout", 0}}, st.Events); diff != "" {
+		t.Errorf("partial output mismatch (-want +got):\n%s", diff)
+	}
+	if st := do("GET", "/jobs/"+st.Id+"?offset=1", "", http.StatusOK); st.Offset != 1 || len(st.Events) != 0 {
+		t.Errorf("GET with offset=1: got %+v, want no new events", st)
+	}
+	if st := do("DELETE", "/jobs/"+st.Id, "", http.StatusOK); st.Status != jobCanceled {
+		t.Errorf("DELETE: got status %q, want %q", st.Status, jobCanceled)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("canceled job's context was not canceled")
+	}
+	poll(st.Id, "", jobCanceled)
+
+	// Finished jobs expire.
+	mu.Lock()
+	now = now.Add(finishedJobTTL + time.Second)
+	mu.Unlock()
+	do("GET", "/jobs/"+st.Id, "", http.StatusNotFound)
+
+	do("GET", "/jobs", "", http.StatusMethodNotAllowed)
+	do("POST", "/jobs", "", http.StatusBadRequest)
+}
+
+func TestJobOutputLimits(t *testing.T) {
+	js := newJobStore()
+	add := func() *job {
+		t.Helper()
+		j, err := js.add()
+		if err != nil {
+			t.Fatalf("add: %v", err)
+		}
+		return j
+	}
+	big := strings.Repeat("x", maxJobOutput/2+1)
+
+	// A job keeps at most maxJobOutput bytes of streamed output.
+	j := add()
+	j.addEvent(Event{big, "stdout", 0})
+	j.addEvent(Event{big, "stdout", 0})
+	j.addEvent(Event{"", "stdout", 0})
+	if st := j.snapshot(0); len(st.Events) != 2 || !st.Truncated {
+		t.Errorf("streamed output: got %d events, truncated %v; want 2, true", len(st.Events), st.Truncated)
+	}
+
+	// And of output in its result, which is truncated in a copy.
+	result := &response{Events: []Event{{big, "stdout", 0}, {big, "stdout", 0}}}
+	j.finish(jobDone, result, time.Now())
+	if st := j.snapshot(0); len(st.Result.Events) != 1 || !st.Truncated {
+		t.Errorf("result: got %d events, truncated %v; want 1, true", len(st.Result.Events), st.Truncated)
+	}
+	if len(result.Events) != 2 {
+		t.Errorf("finish modified the result")
+	}
+	if got := js.size.Load(); got != int64(len(big)) {
+		t.Errorf("retained output: got %d bytes, want %d", got, len(big))
+	}
+
+	// Jobs together keep at most maxJobsOutput bytes.
+	js.size.Store(maxJobsOutput - 1)
+	j = add()
+	j.addEvent(Event{"xx", "stdout", 0})
+	if st := j.snapshot(0); len(st.Events) != 0 || !st.Truncated {
+		t.Errorf("output over the total limit: got %d events, truncated %v; want 0, true", len(st.Events), st.Truncated)
+	}
+}
+
+func TestJobsRateLimit(t *testing.T) {
+	limits, _ := parseRateLimits("poll=1/m")
+	s, err := newServer(func(s *server) error {
+		s.db = &inMemStore{}
+		s.log = newStdLogger()
+		s.cache = new(inMemCache)
+		s.limiter = newRateLimiter(limits, "", nil)
+		var err error
+		s.examples, err = newExamplesHandler(time.Now())
+		return err
+	})
+	if err != nil {
+		t.Fatalf("newServer: %v", err)
+	}
+	for i, want := range []int{http.StatusNotFound, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/jobs/0123", nil))
+		if w.Code != want {
+			t.Errorf("request %d: got status %d, want %d", i, w.Code, want)
+		}
+	}
+}
@@ -5,23 +5,93 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"net/http"
 	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/playground/internal/config"
 )
 
 var log = newStdLogger()
 
 var (
+	configFile = flag.String("config", os.Getenv("PLAY_CONFIG"), "YAML configuration file shared with the sandbox; see playground.example.yaml. Environment variables and flags override it.")
 	runtests   = flag.Bool("runtests", false, "Run integration tests instead of Playground server.")
-	backendURL = flag.String("backend-url", "", "URL for sandbox backend that runs Go binaries.")
 )
 
+func init() {
+	// These flags override the configuration; see config.Load.
+	// Their defaults are only shown in the help message.
+	d := config.Default().Web
+	flag.String("backend-url", d.BackendURL, "URL for sandbox backend that runs Go binaries.")
+
+	flag.Int("build-workers", d.BuildWorkers, "Maximum number of programs built at once.")
+	flag.Int("build-queue", d.BuildQueue, "Maximum number of programs waiting to be built; more are rejected with 429.")
+
+	flag.String("rate-limits", d.RateLimits, "Per-client request budgets, as budget=N/unit with unit s, m or h; empty disables rate limiting.")
+	flag.String("trusted-proxy-header", d.TrustedProxyHeader, "Header in which a trusted reverse proxy passes the client address, such as X-Forwarded-For.")
+	flag.String("api-tokens-file", d.APITokensFile, "File of API tokens, one per line; clients sending one as a bearer token are rate limited by token instead of address.")
+
+	flag.String("cache-dir", d.CacheDir, "Directory for the build and module caches shared by all builds; empty gives each build fresh caches.")
+	flag.Int64("build-cache-mb", d.BuildCacheMB, "Size bound of the shared build cache, in MiB.")
+	flag.Int64("mod-cache-mb", d.ModCacheMB, "Size bound of the shared module cache, in MiB.")
+
+	flag.String("module-allow", d.ModuleAllow, "Comma-separated glob patterns of the module paths snippets may depend on, as in GOPRIVATE; empty allows all modules not denied.")
+	flag.String("module-deny", d.ModuleDeny, "Comma-separated glob patterns of the module paths snippets may not depend on, as in GOPRIVATE.")
+	flag.String("module-dir", d.ModuleDir, "Directory of module zips or of a module cache to serve as the default GOPROXY on a loopback port; unused if missing or empty.")
+
+	flag.String("analyzers", d.Analyzers, "Comma-separated analyzers beyond go vet's that requests may select, among "+strings.Join(analyzerNames(), ", ")+".")
+	flag.String("vuln-db", d.VulnDB, "Directory of a local mirror of the Go vulnerability database, as served at https://vuln.go.dev, to check snippets against at /vulncheck with govulncheck; empty disables it.")
+
+	flag.Duration("metrics-period", d.MetricsPeriod, "How often to write the server's metrics to its log; 0 disables them.")
+}
+
 func main() {
+	// go vet runs this binary as its tool for the extra analyzers.
+	runVettool()
+	// And go build runs it as its -toolexec program for /ssa.
+	runSSATool()
+
 	flag.Parse()
+	cfg, err := config.Load(*configFile, flag.CommandLine)
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	c := &cfg.Web
+	configure(c)
+	builds = newBuildPool(c.BuildWorkers, c.BuildQueue)
+	modPolicy = newModulePolicy(c.ModuleAllow, c.ModuleDeny)
+	if enabledAnalyzers, err = parseAnalyzers(c.Analyzers); err != nil {
+		log.Fatalf("Error in analyzers setting: %v", err)
+	}
+	if vulnDB != "" {
+		if _, err := vulnDBModified(); err != nil {
+			log.Fatalf("Error reading vulnerability database: %v", err)
+		}
+		if _, err := exec.LookPath("govulncheck"); err != nil {
+			log.Fatalf("Vulnerability checking needs govulncheck: %v", err)
+		}
+	}
+	if c.CacheDir != "" {
+		sc, err := newSharedCaches(c.CacheDir, c.BuildCacheMB<<20, c.ModCacheMB<<20)
+		if err != nil {
+			log.Fatalf("Error creating caches: %v", err)
+		}
+		sc.trim()
+		go sc.trimEvery(context.Background(), cacheTrimPeriod)
+		caches = sc
+	}
+	if c.MetricsPeriod > 0 {
+		if err := exportViews(log, c.MetricsPeriod); err != nil {
+			log.Fatalf("Error registering metrics: %v", err)
+		}
+	}
 	s, err := newServer(func(s *server) error {
 		s.db = &inMemStore{}
-		if caddr := os.Getenv("MEMCACHED_ADDR"); caddr != "" {
+		if caddr := c.MemcachedAddr; caddr != "" {
 			s.cache = newGobCache(caddr)
 			log.Printf("Use Memcached caching results")
 		} else {
@@ -40,6 +110,29 @@ func main() {
 			return err
 		}
 		s.examples = eh
+		if fi, err := os.Stat(c.ModuleDir); err == nil && fi.IsDir() {
+			p, err := newModuleProxy(c.ModuleDir)
+			if err != nil {
+				return err
+			}
+			if !p.empty() {
+				log.Printf("Serving %d modules from %s", len(p.mods), c.ModuleDir)
+				s.goproxy = p
+			}
+		}
+		if c.RateLimits != "" {
+			limits, err := parseRateLimits(c.RateLimits)
+			if err != nil {
+				return err
+			}
+			tokens := map[string]bool{}
+			if c.APITokensFile != "" {
+				if tokens, err = readAPITokens(c.APITokensFile); err != nil {
+					return err
+				}
+			}
+			s.limiter = newRateLimiter(limits, c.TrustedProxyHeader, tokens)
+		}
 		return nil
 	})
 	if err != nil {
@@ -50,14 +143,14 @@ func main() {
 		s.test()
 		return
 	}
-	if *backendURL != "" {
-		// TODO(golang.org/issue/25224) - Remove environment variable and use a flag.
-		os.Setenv("SANDBOX_BACKEND_URL", *backendURL)
-	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	port := c.Port
+	if s.goproxy != nil {
+		url, err := s.goproxy.serve()
+		if err != nil {
+			log.Fatalf("Error listening for the module proxy: %v", err)
+		}
+		localGoproxy, localModules = url, s.goproxy.paths()
 	}
 
 	// Get the backend dialer warmed up. This starts
@@ -67,3 +160,25 @@ func main() {
 	log.Printf("Listening on :%v ...", port)
 	log.Fatalf("Error listening on :%v: %v", port, http.ListenAndServe(":"+port, s))
 }
+
+// configure applies the settings of c that are package variables or
+// environment variables read when needed.
+func configure(c *config.Web) {
+	maxBuildTime, maxRunTime = c.MaxBuildTime, c.MaxRunTime
+	maxSnippetSize = c.MaxSnippetSize
+	limitNumFiles, limitNumVendorFiles, maxVendorSize = c.MaxFiles, c.MaxVendorFiles, c.MaxVendorSize
+	vulnDB = c.VulnDB
+	for k, v := range map[string]string{
+		"SANDBOX_BACKEND_URL":   c.BackendURL,
+		"PLAY_GOPROXY":          c.Goproxy,
+		"PLAY_GOSUMDB":          c.Gosumdb,
+		"PLAY_GOPROXY_FALLBACK": c.GoproxyFallback,
+		"GOPRIVATE":             c.GoPrivate,
+		"GONOPROXY":             c.GoNoProxy,
+		"GONOSUMDB":             c.GoNoSumDB,
+	} {
+		if v != "" {
+			os.Setenv(k, v)
+		}
+	}
+}
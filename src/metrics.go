@@ -5,6 +5,10 @@
 package main
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
@@ -15,9 +19,14 @@ var (
 	kGoBuildSuccess          = tag.MustNewKey("go-playground/frontend/go_build_success")
 	kGoRunSuccess            = tag.MustNewKey("go-playground/frontend/go_run_success")
 	kGoVetSuccess            = tag.MustNewKey("go-playground/frontend/go_vet_success")
+	kGoBuildQueueOutcome     = tag.MustNewKey("go-playground/frontend/go_build_queue_outcome")
+	kRateLimitBudget         = tag.MustNewKey("go-playground/frontend/rate_limit_budget")
+	kRateLimitOutcome        = tag.MustNewKey("go-playground/frontend/rate_limit_outcome")
 	mGoBuildLatency          = stats.Float64("go-playground/frontend/go_build_latency", "", stats.UnitMilliseconds)
 	mGoRunLatency            = stats.Float64("go-playground/frontend/go_run_latency", "", stats.UnitMilliseconds)
 	mGoVetLatency            = stats.Float64("go-playground/frontend/go_vet_latency", "", stats.UnitMilliseconds)
+	mGoBuildQueueLatency     = stats.Float64("go-playground/frontend/go_build_queue_latency", "", stats.UnitMilliseconds)
+	mRateLimitRequests       = stats.Int64("go-playground/frontend/rate_limit_requests", "", stats.UnitDimensionless)
 
 	goBuildCount = &view.View{
 		Name:        "go-playground/frontend/go_build_count",
@@ -58,10 +67,30 @@ var (
 		Measure:     mGoVetLatency,
 		Aggregation: BuildLatencyDistribution,
 	}
+	goBuildQueueCount = &view.View{
+		Name:        "go-playground/frontend/go_build_queue_count",
+		Description: "Number of builds that asked for a build worker, by outcome",
+		Measure:     mGoBuildQueueLatency,
+		TagKeys:     []tag.Key{kGoBuildQueueOutcome},
+		Aggregation: view.Count(),
+	}
+	goBuildQueueLatency = &view.View{
+		Name:        "go-playground/frontend/go_build_queue_latency",
+		Description: "Latency distribution of waiting for a build worker",
+		Measure:     mGoBuildQueueLatency,
+		Aggregation: BuildLatencyDistribution,
+	}
+	rateLimitCount = &view.View{
+		Name:        "go-playground/frontend/rate_limit_count",
+		Description: "Number of rate limited requests, by budget and outcome",
+		Measure:     mRateLimitRequests,
+		TagKeys:     []tag.Key{kRateLimitBudget, kRateLimitOutcome},
+		Aggregation: view.Count(),
+	}
 )
 
 // views should contain all measurements. All *view.View added to this
-// slice will be registered and exported to the metric service.
+// slice will be registered and exported by exportViews.
 var views = []*view.View{
 	goBuildCount,
 	goBuildLatency,
@@ -69,4 +98,46 @@ var views = []*view.View{
 	goRunLatency,
 	goVetCount,
 	goVetLatency,
+	goBuildQueueCount,
+	goBuildQueueLatency,
+	rateLimitCount,
+}
+
+// exportViews registers views and writes their data to l every period.
+func exportViews(l logger, period time.Duration) error {
+	if err := view.Register(views...); err != nil {
+		return err
+	}
+	view.SetReportingPeriod(period)
+	view.RegisterExporter(logExporter{l})
+	return nil
+}
+
+// logExporter is a view.Exporter writing the data of views to a
+// logger, a line per row, such as
+//
+//	metric go-playground/frontend/go_build_count{go_build_success=success} count=3
+type logExporter struct {
+	log logger
+}
+
+func (e logExporter) ExportView(d *view.Data) {
+	for _, row := range d.Rows {
+		var tags []string
+		for _, t := range row.Tags {
+			tags = append(tags, t.Key.Name()[strings.LastIndex(t.Key.Name(), "/")+1:]+"="+t.Value)
+		}
+		var value string
+		switch data := row.Data.(type) {
+		case *view.CountData:
+			value = fmt.Sprintf("count=%d", data.Value)
+		case *view.DistributionData:
+			value = fmt.Sprintf("count=%d mean=%g min=%g max=%g", data.Count, data.Mean, data.Min, data.Max)
+		case *view.SumData:
+			value = fmt.Sprintf("sum=%g", data.Value)
+		case *view.LastValueData:
+			value = fmt.Sprintf("value=%g", data.Value)
+		}
+		e.log.Printf("metric %s{%s} %s", d.View.Name, strings.Join(tags, ","), value)
+	}
 }
@@ -0,0 +1,47 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// recordingLogger is a logger recording the lines written with Printf.
+type recordingLogger struct {
+	logger
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestLogExporter(t *testing.T) {
+	l := new(recordingLogger)
+	logExporter{l}.ExportView(&view.Data{
+		View: rateLimitCount,
+		Rows: []*view.Row{
+			{Tags: []tag.Tag{{Key: kRateLimitBudget, Value: "compile"}, {Key: kRateLimitOutcome, Value: "rejected"}}, Data: &view.CountData{Value: 3}},
+		},
+	})
+	logExporter{l}.ExportView(&view.Data{
+		View: goBuildQueueLatency,
+		Rows: []*view.Row{
+			{Data: &view.DistributionData{Count: 2, Min: 1, Max: 3, Mean: 2}},
+		},
+	})
+	want := []string{
+		"metric go-playground/frontend/rate_limit_count{rate_limit_budget=compile,rate_limit_outcome=rejected} count=3",
+		"metric go-playground/frontend/go_build_queue_latency{} count=2 mean=2 min=1 max=3",
+	}
+	if diff := cmp.Diff(want, l.lines); diff != "" {
+		t.Errorf("exported lines mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,512 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/playground/internal"
+)
+
+// modPolicy restricts the modules snippets may depend on. It is set in
+// main according to the -module-allow and -module-deny flags; if nil,
+// any module may be used.
+var modPolicy *modulePolicy
+
+// modulePolicy is a set of allow and deny patterns for module paths,
+// since a snippet's go.mod can otherwise make the playground fetch any
+// module through the configured proxy, with its credentials.
+//
+// Patterns are comma-separated lists of glob patterns matching module
+// path prefixes, as in GOPRIVATE.
+type modulePolicy struct {
+	allow string // if non-empty, only modules matching it are allowed
+	deny  string // modules matching it are denied, even if allowed
+}
+
+// newModulePolicy returns the policy for the allow and deny pattern
+// lists, or nil if both are empty.
+func newModulePolicy(allow, deny string) *modulePolicy {
+	if allow == "" && deny == "" {
+		return nil
+	}
+	return &modulePolicy{allow: allow, deny: deny}
+}
+
+// allowed reports whether the module with the given path may be used.
+func (p *modulePolicy) allowed(path string) bool {
+	if p.deny != "" && module.MatchPrefixPatterns(p.deny, path) {
+		return false
+	}
+	return p.allow == "" || module.MatchPrefixPatterns(p.allow, path)
+}
+
+// checkFiles checks the go.mod files, the go.work file and the imports
+// of a snippet. It returns an error for each module that is not
+// allowed.
+func (p *modulePolicy) checkFiles(files *fileSet) []compileError {
+	var errs []compileError
+	var mainModules []string
+	for _, name := range files.files {
+		switch {
+		case name == "go.mod" || strings.HasSuffix(name, "/go.mod"):
+			errs = append(errs, p.checkGoMod(name, files.Data(name))...)
+			if mod := modfile.ModulePath(files.Data(name)); mod != "" {
+				mainModules = append(mainModules, mod)
+			}
+		case name == "go.work":
+			errs = append(errs, p.checkGoWork(name, files.Data(name))...)
+		}
+	}
+	for _, name := range files.files {
+		if strings.HasSuffix(name, ".go") && !strings.HasPrefix(name, "vendor/") {
+			errs = append(errs, p.checkImports(name, files.Data(name), mainModules)...)
+		}
+	}
+	return errs
+}
+
+// checkImports checks the imports of the Go file data, other than those
+// of the standard library and of the packages of mainModules. With
+// -mod=mod, the go command looks up the module of an import that no
+// requirement provides, so the import path is checked as if it were
+// the module path. It returns an error for each import that is not
+// allowed. Errors parsing data are left for the go command to report.
+func (p *modulePolicy) checkImports(file string, data []byte, mainModules []string) []compileError {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, data, parser.ImportsOnly)
+	if err != nil {
+		return nil
+	}
+	var errs []compileError
+	for _, spec := range f.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || !strings.Contains(strings.Split(path, "/")[0], ".") || inModules(path, mainModules) {
+			continue
+		}
+		if !p.allowed(path) {
+			errs = append(errs, compileError{
+				File:    file,
+				Line:    fset.Position(spec.Pos()).Line,
+				Message: fmt.Sprintf("package %s is not allowed in the playground", path),
+			})
+		}
+	}
+	return errs
+}
+
+// inModules reports whether the package path is in one of modules.
+func inModules(path string, modules []string) bool {
+	for _, mod := range modules {
+		if path == mod || strings.HasPrefix(path, mod+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGoMod checks the modules required or substituted by replace
+// directives in the go.mod file data. It returns an error for each
+// module that is not allowed. Errors parsing data are left for the go
+// command to report.
+func (p *modulePolicy) checkGoMod(file string, data []byte) []compileError {
+	f, err := modfile.Parse(file, data, nil)
+	if err != nil {
+		return nil
+	}
+	var errs []compileError
+	for _, r := range f.Require {
+		errs = p.check(errs, file, r.Mod.Path, r.Syntax)
+	}
+	for _, r := range f.Replace {
+		errs = p.checkReplace(errs, file, r)
+	}
+	return errs
+}
+
+// checkGoWork is like checkGoMod for a go.work file, whose only
+// modules from outside the snippet come from replace directives.
+func (p *modulePolicy) checkGoWork(file string, data []byte) []compileError {
+	f, err := modfile.ParseWork(file, data, nil)
+	if err != nil {
+		return nil
+	}
+	var errs []compileError
+	for _, r := range f.Replace {
+		errs = p.checkReplace(errs, file, r)
+	}
+	return errs
+}
+
+func (p *modulePolicy) checkReplace(errs []compileError, file string, r *modfile.Replace) []compileError {
+	if modfile.IsDirectoryPath(r.New.Path) {
+		return errs
+	}
+	return p.check(errs, file, r.New.Path, r.Syntax)
+}
+
+// check appends an error to errs if the module path, used on line of
+// file, is not allowed.
+func (p *modulePolicy) check(errs []compileError, file, path string, line *modfile.Line) []compileError {
+	if p.allowed(path) {
+		return errs
+	}
+	e := compileError{File: file, Message: policyMessage(path)}
+	if line != nil {
+		e.Line = line.Start.Line
+	}
+	return append(errs, e)
+}
+
+// checkRequirements walks the module graph of the snippet written to
+// dir, from the requirements of its main modules, the way the go
+// command loads it. It returns an error for each module that is not
+// allowed. Unlike checkGraph, it checks each module before the go.mod
+// file of that module is fetched, by goBin with the environment env of
+// the build, so that nothing is downloaded for a denied module, with
+// the build's proxy and credentials, even to reject it. If fetching
+// fails, the go command's errors are returned instead.
+func (p *modulePolicy) checkRequirements(ctx context.Context, goBin, dir string, env []string) []compileError {
+	w := &graphWalker{
+		p:       p,
+		main:    map[string]bool{},
+		replace: map[module.Version]module.Version{},
+		denied:  map[string]bool{},
+	}
+	roots, pruned := w.readMain(dir)
+	type pending struct {
+		mod      module.Version
+		unpruned bool // load the whole graph below mod
+	}
+	var queue []pending
+	for _, m := range roots {
+		queue = append(queue, pending{m, !pruned})
+	}
+	loaded := map[pending]bool{}
+	for len(queue) > 0 {
+		var next, fetching []pending
+		var fetch []module.Version
+		for _, q := range queue {
+			if w.main[q.mod.Path] || loaded[q] || !w.allowed(q.mod.Path) {
+				continue
+			}
+			loaded[q] = true
+			r := w.replacement(q.mod)
+			if r.Version == "" {
+				// Replaced by a directory; a missing go.mod
+				// file is left for the go command to report.
+				data, err := os.ReadFile(filepath.Join(r.Path, "go.mod"))
+				if err != nil {
+					continue
+				}
+				for _, m := range w.requirements(data, q.unpruned) {
+					next = append(next, pending{m, true})
+				}
+				continue
+			}
+			if r != q.mod && !w.allowed(r.Path) {
+				continue
+			}
+			fetching, fetch = append(fetching, q), append(fetch, r)
+		}
+		if len(fetch) > 0 {
+			mods, errs := goModFiles(ctx, goBin, env, fetch)
+			if len(errs) > 0 {
+				return errs
+			}
+			for i, q := range fetching {
+				for _, m := range w.requirements(mods[i], q.unpruned) {
+					next = append(next, pending{m, true})
+				}
+			}
+		}
+		queue = next
+	}
+	return w.errs
+}
+
+// graphWalker is the state of checkRequirements.
+type graphWalker struct {
+	p    *modulePolicy
+	main map[string]bool // paths of the main modules
+	// replace maps modules to their replacements, from the replace
+	// directives of the main modules; a key without a version
+	// replaces all versions, and a replacement without a version is
+	// the absolute path of a directory.
+	replace map[module.Version]module.Version
+	denied  map[string]bool
+	errs    []compileError
+}
+
+// readMain reads the go.mod files of the main modules of the snippet in
+// dir, or the go.work file in a workspace. It returns their
+// requirements and whether the module graph is pruned, as it is from go
+// 1.17 on. Errors reading or parsing the files are left for the go
+// command to report.
+func (w *graphWalker) readMain(dir string) (roots []module.Version, pruned bool) {
+	if data, err := os.ReadFile(filepath.Join(dir, "go.work")); err == nil {
+		wf, err := modfile.ParseWork("go.work", data, nil)
+		if err != nil {
+			return nil, true
+		}
+		for _, u := range wf.Use {
+			f := w.readGoMod(filepath.Join(dir, u.Path))
+			if f == nil {
+				continue
+			}
+			for _, r := range f.Require {
+				roots = append(roots, r.Mod)
+			}
+		}
+		// The replace directives of go.work override those of
+		// the modules in the workspace.
+		w.addReplaces(dir, wf.Replace)
+		return roots, true
+	}
+	f := w.readGoMod(dir)
+	if f == nil {
+		return nil, true
+	}
+	for _, r := range f.Require {
+		roots = append(roots, r.Mod)
+	}
+	return roots, prunes(f)
+}
+
+// readGoMod reads the go.mod file of the main module in dir.
+func (w *graphWalker) readGoMod(dir string) *modfile.File {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil
+	}
+	f, err := modfile.Parse("go.mod", data, nil)
+	if err != nil || f.Module == nil {
+		return nil
+	}
+	w.main[f.Module.Mod.Path] = true
+	w.addReplaces(dir, f.Replace)
+	return f
+}
+
+// addReplaces adds the replace directives of a go.mod or go.work file
+// in dir.
+func (w *graphWalker) addReplaces(dir string, replaces []*modfile.Replace) {
+	for _, r := range replaces {
+		to := r.New
+		if modfile.IsDirectoryPath(to.Path) {
+			to = module.Version{Path: filepath.Join(dir, to.Path)}
+		}
+		w.replace[r.Old] = to
+	}
+}
+
+// replacement returns the replacement of m, or m if it is not replaced.
+func (w *graphWalker) replacement(m module.Version) module.Version {
+	if r, ok := w.replace[m]; ok {
+		return r
+	}
+	if r, ok := w.replace[module.Version{Path: m.Path}]; ok {
+		return r
+	}
+	return m
+}
+
+// allowed is like modulePolicy.allowed, recording an error the first
+// time a module is not allowed.
+func (w *graphWalker) allowed(path string) bool {
+	if w.p.allowed(path) {
+		return true
+	}
+	if !w.denied[path] {
+		w.denied[path] = true
+		w.errs = append(w.errs, compileError{Message: policyMessage(path)})
+	}
+	return false
+}
+
+// requirements returns the requirements in the go.mod file data of a
+// module whose go.mod file the go command loads, and whose own
+// requirements' go.mod files it loads as well: all of them if the graph
+// below the module is unpruned, or if the module's go.mod file does not
+// prune it. Otherwise, the requirements are in the graph without their
+// go.mod files being loaded, so they are checked here and none is
+// returned.
+func (w *graphWalker) requirements(data []byte, unpruned bool) []module.Version {
+	f, err := modfile.ParseLax("go.mod", data, nil)
+	if err != nil {
+		return nil
+	}
+	var reqs []module.Version
+	for _, r := range f.Require {
+		reqs = append(reqs, r.Mod)
+	}
+	if unpruned || !prunes(f) {
+		return reqs
+	}
+	for _, m := range reqs {
+		w.allowed(m.Path)
+	}
+	return nil
+}
+
+// prunes reports whether the go.mod file f prunes the module graph, as
+// it does from go 1.17 on.
+func prunes(f *modfile.File) bool {
+	return f.Go != nil && semver.Compare("v"+f.Go.Version, "v1.17") >= 0
+}
+
+// goModFiles fetches the go.mod files of mods with goBin and env, outside
+// of any module, and returns their contents in the same order. If the go
+// command fails, its errors are returned instead.
+func goModFiles(ctx context.Context, goBin string, env []string, mods []module.Version) ([][]byte, []compileError) {
+	dir, err := os.MkdirTemp("", "modgraph-")
+	if err != nil {
+		return nil, []compileError{{Message: fmt.Sprintf("error listing modules: %v", err)}}
+	}
+	defer os.RemoveAll(dir)
+	args := []string{"list", "-m", "-json", "-e"}
+	for _, m := range mods {
+		args = append(args, m.Path+"@"+m.Version)
+	}
+	cmd := exec.Command(goBin, args...)
+	cmd.Dir = dir
+	cmd.Env = append(append([]string(nil), env...), "GOFLAGS=", "GOWORK=off")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, []compileError{{Message: fmt.Sprintf("error listing modules: %v", err)}}
+	}
+	if err := internal.WaitOrStop(ctx, cmd, os.Interrupt, 250*time.Millisecond); err != nil {
+		if errs := parseCompileErrors(stderr.Bytes(), dir); len(errs) > 0 {
+			return nil, errs
+		}
+		return nil, []compileError{{Message: fmt.Sprintf("error listing modules: %v", err)}}
+	}
+	var (
+		data [][]byte
+		errs []compileError
+	)
+	dec := json.NewDecoder(&stdout)
+	for {
+		var m struct {
+			GoMod string
+			Error *struct{ Err string }
+		}
+		if err := dec.Decode(&m); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, []compileError{{Message: fmt.Sprintf("error listing modules: %v", err)}}
+		}
+		if m.Error != nil {
+			errs = append(errs, compileError{Message: m.Error.Err})
+			continue
+		}
+		b, err := os.ReadFile(m.GoMod)
+		if err != nil {
+			errs = append(errs, compileError{Message: fmt.Sprintf("error listing modules: %v", err)})
+			continue
+		}
+		data = append(data, b)
+	}
+	if len(errs) == 0 && len(data) != len(mods) {
+		errs = append(errs, compileError{Message: fmt.Sprintf("error listing modules: got %d go.mod files for %d modules", len(data), len(mods))})
+	}
+	return data, errs
+}
+
+// listModulesArgs returns the arguments of the go command that lists,
+// one per line, the modules used to build pkg other than the main
+// modules, each followed by its replacement if any. mod is the -mod
+// flag of the build, as returned by modFlag.
+//
+// With -mod=mod, that is the whole build list. Otherwise, it is the
+// modules providing the packages pkg imports: the build list cannot be
+// computed from a vendor directory, and in a workspace, "go list -m all"
+// also loads the go.mod files of the versions of workspace modules that
+// their requirements name.
+func listModulesArgs(mod, pkg string) []string {
+	const format = "{{if not .Main}}{{.Path}}{{with .Replace}} {{.Path}}{{end}}{{end}}"
+	if mod == "-mod=mod" {
+		return []string{"list", "-m", mod, "-f", format, "all"}
+	}
+	args := []string{"list", "-deps"}
+	if mod != "" {
+		args = append(args, mod)
+	}
+	return append(args, "-f", "{{with .Module}}"+format+"{{end}}", pkg)
+}
+
+// checkGraph checks the modules listed by cmd, a go command set up like
+// the build with the arguments from listModulesArgs, after
+// checkRequirements has kept the listing from fetching anything for a
+// denied module. It returns an
+// error for each module that is not allowed. If the go command fails,
+// its errors are returned instead, since the modules in use are not
+// known.
+func (p *modulePolicy) checkGraph(ctx context.Context, cmd *exec.Cmd) []compileError {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Start(); err != nil {
+		return []compileError{{Message: fmt.Sprintf("error listing modules: %v", err)}}
+	}
+	if err := internal.WaitOrStop(ctx, cmd, os.Interrupt, 250*time.Millisecond); err != nil {
+		if errs := parseCompileErrors(stderr.Bytes(), cmd.Dir); len(errs) > 0 {
+			return errs
+		}
+		return []compileError{{Message: fmt.Sprintf("error listing modules: %v", err)}}
+	}
+	var errs []compileError
+	seen := map[string]bool{}
+	sc := bufio.NewScanner(&stdout)
+	for sc.Scan() {
+		for _, path := range strings.Fields(sc.Text()) {
+			if seen[path] || modfile.IsDirectoryPath(path) || p.allowed(path) {
+				continue
+			}
+			seen[path] = true
+			errs = append(errs, compileError{Message: policyMessage(path)})
+		}
+	}
+	return errs
+}
+
+func policyMessage(path string) string {
+	return fmt.Sprintf("module %s is not allowed in the playground", path)
+}
+
+// policyErrorMessage formats errs for buildResult.errorMessage.
+func policyErrorMessage(errs []compileError) string {
+	var b strings.Builder
+	for _, e := range errs {
+		switch {
+		case e.File != "" && e.Line != 0 && e.Column != 0:
+			fmt.Fprintf(&b, "%s:%d:%d: %s\n", e.File, e.Line, e.Column, e.Message)
+		case e.File != "" && e.Line != 0:
+			fmt.Fprintf(&b, "%s:%d: %s\n", e.File, e.Line, e.Message)
+		case e.File != "":
+			fmt.Fprintf(&b, "%s: %s\n", e.File, e.Message)
+		default:
+			fmt.Fprintf(&b, "%s\n", e.Message)
+		}
+	}
+	return b.String()
+}
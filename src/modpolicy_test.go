@@ -0,0 +1,258 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestModulePolicyAllowed(t *testing.T) {
+	tests := []struct {
+		allow, deny string
+		path        string
+		want        bool
+	}{
+		{allow: "", deny: "example.com/private", path: "example.com/public", want: true},
+		{allow: "", deny: "example.com/private", path: "example.com/private/sub", want: false},
+		{allow: "golang.org/x,github.com/google/*", deny: "", path: "golang.org/x/tools", want: true},
+		{allow: "golang.org/x,github.com/google/*", deny: "", path: "github.com/google/go-cmp", want: true},
+		{allow: "golang.org/x,github.com/google/*", deny: "", path: "github.com/evil/mod", want: false},
+		{allow: "*.corp.example", deny: "secret.corp.example", path: "git.corp.example/team/mod", want: true},
+		{allow: "*.corp.example", deny: "secret.corp.example", path: "secret.corp.example/mod", want: false},
+	}
+	for _, tt := range tests {
+		p := newModulePolicy(tt.allow, tt.deny)
+		if got := p.allowed(tt.path); got != tt.want {
+			t.Errorf("allow %q deny %q: allowed(%q) = %v; want %v", tt.allow, tt.deny, tt.path, got, tt.want)
+		}
+	}
+	if p := newModulePolicy("", ""); p != nil {
+		t.Errorf("newModulePolicy(\"\", \"\") = %+v; want nil", p)
+	}
+}
+
+func TestModulePolicyCheckGoMod(t *testing.T) {
+	const goMod = `module play
+
+require (
+	golang.org/x/text v0.3.7
+	example.com/private v1.0.0
+)
+
+replace golang.org/x/text => example.com/fork/text v0.3.7
+
+replace example.com/private => ./private
+`
+	p := newModulePolicy("", "example.com/private,example.com/fork")
+	want := []compileError{
+		{File: "go.mod", Line: 5, Message: "module example.com/private is not allowed in the playground"},
+		{File: "go.mod", Line: 8, Message: "module example.com/fork/text is not allowed in the playground"},
+	}
+	got := p.checkGoMod("go.mod", []byte(goMod))
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("checkGoMod mismatch (-want +got):\n%s", diff)
+	}
+	const wantMsg = "go.mod:5: module example.com/private is not allowed in the playground\n" +
+		"go.mod:8: module example.com/fork/text is not allowed in the playground\n"
+	if msg := policyErrorMessage(got); msg != wantMsg {
+		t.Errorf("policyErrorMessage = %q; want %q", msg, wantMsg)
+	}
+
+	if errs := p.checkGoMod("go.mod", []byte("this is not a go.mod")); errs != nil {
+		t.Errorf("checkGoMod of a bad go.mod = %v; want nil", errs)
+	}
+}
+
+func TestModulePolicyCheckImports(t *testing.T) {
+	files, err := splitFiles([]byte(`package main
+
+import (
+	"fmt"
+
+	"denied.example.com/x"
+	"example.com/ok"
+	"play/sub"
+)
+
+func main() { fmt.Println(x.X, ok.OK, sub.Sub) }
+-- go.mod --
+module play
+-- sub/sub.go --
+package sub
+
+import _ "denied.example.com/y/z"
+
+const Sub = 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newModulePolicy("", "denied.example.com,play")
+	want := []compileError{
+		{File: "prog.go", Line: 6, Message: "package denied.example.com/x is not allowed in the playground"},
+		{File: "sub/sub.go", Line: 3, Message: "package denied.example.com/y/z is not allowed in the playground"},
+	}
+	if diff := cmp.Diff(want, p.checkFiles(files)); diff != "" {
+		t.Errorf("checkFiles mismatch (-want +got):\n%s", diff)
+	}
+
+	// Without a go.mod file, the import of a denied package is the
+	// only trace of its module.
+	files, err = splitFiles([]byte("package main\n\nimport _ \"denied.example.com/x\"\n\nfunc main() {}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files.AddFile("go.mod", []byte("module play\n"))
+	want = []compileError{{File: "prog.go", Line: 3, Message: "package denied.example.com/x is not allowed in the playground"}}
+	if diff := cmp.Diff(want, p.checkFiles(files)); diff != "" {
+		t.Errorf("checkFiles of an import-only snippet mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestModulePolicyCheckGraph(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":           "module play\n\nrequire example.com/a v0.0.0\n\nreplace example.com/a => ./a\n\nreplace example.com/b => ./b\n",
+		"main.go":          "package main\n\nimport _ \"example.com/a\"\n\nfunc main() {}\n",
+		"a/go.mod":         "module example.com/a\n\nrequire example.com/b v0.0.0\n",
+		"a/a.go":           "package a\n\nimport _ \"example.com/b\"\n",
+		"b/go.mod":         "module example.com/b\n",
+		"b/b.go":           "package b\n",
+		"gocache/.keep":    "",
+		"gomodcache/.keep": "",
+	}
+	write := func(files map[string]string) {
+		t.Helper()
+		for name, data := range files {
+			name = filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	write(files)
+
+	// example.com/b is only required indirectly, through example.com/a.
+	p := newModulePolicy("", "example.com/b")
+	if errs := p.checkGoMod("go.mod", []byte(files["go.mod"])); errs != nil {
+		t.Fatalf("checkGoMod = %v; want nil", errs)
+	}
+	env := append(os.Environ(), "GOPROXY=off", "GOFLAGS=", "GOWORK=",
+		"GOCACHE="+filepath.Join(dir, "gocache"), "GOMODCACHE="+filepath.Join(dir, "gomodcache"))
+	checkGraph := func(pkg string) []compileError {
+		cmd := exec.Command(goBin, listModulesArgs(modFlag(dir), pkg)...)
+		cmd.Dir, cmd.Env = dir, env
+		return p.checkGraph(context.Background(), cmd)
+	}
+	want := []compileError{{Message: "module example.com/b is not allowed in the playground"}}
+	if diff := cmp.Diff(want, checkGraph(".")); diff != "" {
+		t.Errorf("checkGraph mismatch (-want +got):\n%s", diff)
+	}
+
+	// In a workspace, example.com/b is still only required through
+	// example.com/a.
+	for _, name := range []string{"go.mod", "main.go"} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	goWork := "go 1.18\n\nuse (\n\t./app\n\t./a\n)\n\nreplace example.com/b => ./b\n"
+	write(map[string]string{
+		"go.work":     goWork,
+		"app/go.mod":  "module play\n",
+		"app/main.go": files["main.go"],
+	})
+	if diff := cmp.Diff(want, checkGraph("./app")); diff != "" {
+		t.Errorf("checkGraph in workspace mismatch (-want +got):\n%s", diff)
+	}
+
+	// Failures are reported, since the modules in use are not known.
+	write(map[string]string{"go.work": goWork + "replace example.com/a => example.com/c v1.0.0\n"})
+	if errs := checkGraph("./app"); len(errs) == 0 {
+		t.Errorf("checkGraph with unresolvable module returned no errors")
+	}
+}
+
+func TestModulePolicyCheckRequirements(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	// A file:// module proxy with example.com/a requiring the denied
+	// example.com/b, which requires example.com/c.
+	proxy := t.TempDir()
+	for path, gomod := range map[string]string{
+		"example.com/a": "module example.com/a\n\ngo 1.16\n\nrequire example.com/b v1.0.0\n",
+		"example.com/b": "module example.com/b\n\nrequire example.com/c v1.0.0\n",
+		"example.com/c": "module example.com/c\n",
+		"example.com/d": "module example.com/d\n\ngo 1.17\n\nrequire example.com/b v1.0.0\n",
+	} {
+		dir := filepath.Join(proxy, path, "@v")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for name, data := range map[string]string{
+			"list":        "v1.0.0\n",
+			"v1.0.0.info": `{"Version":"v1.0.0"}`,
+			"v1.0.0.mod":  gomod,
+		} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	p := newModulePolicy("", "example.com/b")
+	want := []compileError{{Message: "module example.com/b is not allowed in the playground"}}
+	for _, tc := range []struct {
+		name, gomod string
+		want        []compileError
+	}{
+		{"Allowed", "module play\n", nil},
+		{"Indirect", "module play\n\nrequire example.com/a v1.0.0\n", want},
+		// example.com/b is in the pruned graph without its go.mod.
+		{"Pruned", "module play\n\ngo 1.17\n\nrequire example.com/d v1.0.0\n", want},
+		{"Replaced", "module play\n\nrequire example.com/e v1.0.0\n\nreplace example.com/e => ./e\n", want},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			modCache := filepath.Join(dir, "gomodcache")
+			t.Cleanup(func() { removeAll(modCache) })
+			for name, data := range map[string]string{
+				"go.mod":   tc.gomod,
+				"e/go.mod": "module example.com/e\n\nrequire example.com/a v1.0.0\n",
+			} {
+				name = filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			env := append(os.Environ(), "GOPROXY=file://"+filepath.ToSlash(proxy), "GOSUMDB=off", "GOFLAGS=", "GOWORK=",
+				"GOCACHE="+filepath.Join(dir, "gocache"), "GOMODCACHE="+modCache)
+			if diff := cmp.Diff(tc.want, p.checkRequirements(context.Background(), goBin, dir, env)); diff != "" {
+				t.Errorf("checkRequirements mismatch (-want +got):\n%s", diff)
+			}
+			if _, err := os.Stat(filepath.Join(modCache, "cache", "download", "example.com", "b")); err == nil {
+				t.Errorf("checkRequirements downloaded the denied module")
+			}
+		})
+	}
+}
@@ -101,6 +101,10 @@ func (r *Recorder) Events() ([]Event, error) {
 	return out, nil
 }
 
+var playbackMagic = []byte{0, 0, 'P', 'B'}
+
+const playbackHeaderLen = 8 + 4
+
 type event struct {
 	msg  []byte
 	kind string
@@ -109,8 +113,8 @@ type event struct {
 
 func decode(kind string, output []byte) ([]event, error) {
 	var (
-		magic     = []byte{0, 0, 'P', 'B'}
-		headerLen = 8 + 4
+		magic     = playbackMagic
+		headerLen = playbackHeaderLen
 		last      = epoch
 		events    []event
 	)
@@ -214,3 +218,186 @@ func sanitize(b []byte) []byte {
 	}
 	return buf.Bytes()
 }
+
+// streamDecoder is the incremental counterpart of Recorder: it decodes
+// the output of a sandbox program (comprised of playback headers) as it
+// arrives, chunk by chunk, and converts it to Events as soon as possible.
+//
+// Headers and multi-byte UTF-8 sequences may be split across chunks;
+// the incomplete part is kept until the next chunk of the same kind.
+// Writes with the same time within one chunk are merged into one Event.
+// Delays are computed as in Recorder.Events, relative to the time of the
+// last Event of either kind, in the order the chunks are written.
+//
+// The zero value is ready to use.
+type streamDecoder struct {
+	now            time.Time // time of the last Event with a non-zero delay
+	stdout, stderr streamState
+}
+
+// streamState is the decoding state of one output stream.
+type streamState struct {
+	buf    []byte    // undecoded input: a partial header, or what may be one
+	remain int       // number of bytes of the current write still to come
+	last   time.Time // time of the current write; zero means epoch
+	held   []byte    // decoded output ending with an incomplete UTF-8 sequence
+	heldAt time.Time // time of held
+}
+
+// Write decodes the chunk p of the output of the given kind ("stdout"
+// or "stderr") and returns the Events it completes.
+func (d *streamDecoder) Write(kind string, p []byte) ([]Event, error) {
+	st := d.state(kind)
+	if st.last.IsZero() {
+		st.last = epoch
+	}
+	buf := append(st.buf, p...)
+	var writes []event
+	add := func(t time.Time, b []byte) {
+		if len(b) == 0 {
+			return
+		}
+		if n := len(writes); n > 0 && writes[n-1].time.Equal(t) {
+			writes[n-1].msg = append(writes[n-1].msg, b...)
+			return
+		}
+		writes = append(writes, event{msg: append([]byte(nil), b...), kind: kind, time: t})
+	}
+	for len(buf) > 0 {
+		if st.remain > 0 {
+			// Slurp output of the current write.
+			n := st.remain
+			if n > len(buf) {
+				n = len(buf)
+			}
+			add(st.last, buf[:n])
+			buf = buf[n:]
+			st.remain -= n
+			continue
+		}
+		if !bytes.HasPrefix(buf, playbackMagic) {
+			// Not a header; find next header.
+			j := bytes.Index(buf, playbackMagic)
+			if j < 0 {
+				// Keep back what may be the start of a header.
+				j = len(buf) - partialPrefixLen(buf, playbackMagic)
+				add(st.last, buf[:j])
+				buf = buf[j:]
+				break
+			}
+			add(st.last, buf[:j])
+			buf = buf[j:]
+		}
+		if len(buf) < len(playbackMagic)+playbackHeaderLen {
+			break // wait for the rest of the header
+		}
+		header := buf[len(playbackMagic) : len(playbackMagic)+playbackHeaderLen]
+		t := time.Unix(0, int64(binary.BigEndian.Uint64(header[0:])))
+		if t.Before(st.last) {
+			// Force timestamps to be monotonic, as decode does.
+			t = st.last
+		}
+		n := int(binary.BigEndian.Uint32(header[8:]))
+		if n < 0 {
+			return nil, fmt.Errorf("bad length: %v", n)
+		}
+		st.last, st.remain = t, n
+		buf = buf[len(playbackMagic)+playbackHeaderLen:]
+	}
+	st.buf = append(st.buf[:0], buf...)
+
+	var out []Event
+	for _, w := range writes {
+		out = d.emit(out, st, w.kind, w.time, w.msg, false)
+	}
+	return out, nil
+}
+
+// Flush returns the Events for any output kept back waiting for more
+// input. It must be called once the program has exited. Like decode,
+// it reports an error if the output ends in the middle of a header.
+func (d *streamDecoder) Flush() ([]Event, error) {
+	var out []Event
+	for _, kind := range []string{"stdout", "stderr"} {
+		st := d.state(kind)
+		if bytes.HasPrefix(st.buf, playbackMagic) {
+			return nil, errors.New("short header")
+		}
+		out = d.emit(out, st, kind, st.last, st.buf, true)
+		st.buf = nil
+	}
+	return out, nil
+}
+
+// emit appends to out the Event for the output b written at time t,
+// joined with any output held back from before. Unless final is set,
+// an incomplete UTF-8 sequence at the end of b is held back, so that
+// a character split across chunks is not replaced by U+FFFD.
+func (d *streamDecoder) emit(out []Event, st *streamState, kind string, t time.Time, b []byte, final bool) []Event {
+	if len(st.held) > 0 {
+		if t.Equal(st.heldAt) {
+			b = append(st.held, b...)
+		} else {
+			out = d.event(out, kind, st.heldAt, st.held)
+		}
+		st.held = nil
+	}
+	if !final {
+		if k := incompleteRuneLen(b); k > 0 {
+			st.held = append([]byte(nil), b[len(b)-k:]...)
+			st.heldAt = t
+			b = b[:len(b)-k]
+		}
+	}
+	if len(b) == 0 {
+		return out
+	}
+	return d.event(out, kind, t, b)
+}
+
+// event appends the Event for msg written at time t to out.
+func (d *streamDecoder) event(out []Event, kind string, t time.Time, msg []byte) []Event {
+	if d.now.IsZero() {
+		d.now = epoch
+	}
+	delay := t.Sub(d.now)
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > 0 {
+		d.now = t
+	}
+	return append(out, Event{Message: string(sanitize(msg)), Kind: kind, Delay: delay})
+}
+
+func (d *streamDecoder) state(kind string) *streamState {
+	if kind == "stderr" {
+		return &d.stderr
+	}
+	return &d.stdout
+}
+
+// partialPrefixLen returns the length of the longest suffix of b that
+// is a proper prefix of magic.
+func partialPrefixLen(b, magic []byte) int {
+	for n := len(magic) - 1; n > 0; n-- {
+		if len(b) >= n && bytes.Equal(b[len(b)-n:], magic[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// incompleteRuneLen returns the length of the incomplete but so far
+// valid UTF-8 sequence at the end of b, if any.
+func incompleteRuneLen(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if utf8.FullRune(b[i:]) {
+				return 0
+			}
+			return len(b) - i
+		}
+	}
+	return 0
+}
@@ -54,3 +54,93 @@ func pbWrite(offset time.Duration, s string) []byte {
 	binary.BigEndian.PutUint32(out[12:], uint32(len(s)))
 	return append(out, s...)
 }
+
+func TestStreamDecoder(t *testing.T) {
+	var stdout []byte
+	stdout = append(stdout, "head"...)
+	stdout = append(stdout, pbWrite(0, "one")...)
+	stdout = append(stdout, pbWrite(time.Second, "twoé")...)
+	stdout = append(stdout, "middle"...)
+	stdout = append(stdout, pbWrite(3*time.Second, "世界")...)
+	stdout = append(stdout, pbWrite(2*time.Second, "back")...)
+	stdout = append(stdout, "tail\x00\x00"...)
+
+	r := new(Recorder)
+	r.Stdout().Write(stdout)
+	want, err := r.Events()
+	if err != nil {
+		t.Fatalf("Events: %v", err)
+	}
+
+	// Feed the output one byte at a time, splitting every header
+	// and multi-byte character.
+	var (
+		d   streamDecoder
+		got []Event
+	)
+	for i := range stdout {
+		evs, err := d.Write("stdout", stdout[i:i+1])
+		if err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		got = appendMerged(got, evs...)
+	}
+	evs, err := d.Flush()
+	if err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	got = appendMerged(got, evs...)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: \n%q,\nwant \n%q", got, want)
+	}
+}
+
+func TestStreamDecoderInterleaved(t *testing.T) {
+	var d streamDecoder
+	var got []Event
+	for _, w := range []struct {
+		kind string
+		data []byte
+	}{
+		{"stdout", pbWrite(0, "one")},
+		{"stderr", pbWrite(time.Second, "two")[:10]},
+		{"stdout", pbWrite(2*time.Second, "three")},
+		{"stderr", pbWrite(time.Second, "two")[10:]},
+		{"stderr", pbWrite(3*time.Second, "four")},
+	} {
+		evs, err := d.Write(w.kind, w.data)
+		if err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		got = append(got, evs...)
+	}
+	want := []Event{
+		{"one", "stdout", 0},
+		{"three", "stdout", 2 * time.Second},
+		{"two", "stderr", 0},
+		{"four", "stderr", time.Second},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: \n%v,\nwant \n%v", got, want)
+	}
+
+	if _, err := d.Write("stdout", pbWrite(4*time.Second, "")[:8]); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := d.Flush(); err == nil {
+		t.Errorf("Flush with a partial header succeeded, want error")
+	}
+}
+
+// appendMerged appends evs to out, merging events that follow an
+// event of the same kind without delay, as Recorder.Events does.
+func appendMerged(out []Event, evs ...Event) []Event {
+	for _, e := range evs {
+		if n := len(out); n > 0 && out[n-1].Kind == e.Kind && e.Delay == 0 {
+			out[n-1].Message += e.Message
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
@@ -0,0 +1,340 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/google/pprof/profile"
+	"golang.org/x/playground/sandbox/sandboxtypes"
+)
+
+// A profiled program writes its profile to profileFile in the output
+// directory of the sandbox, which returns it with the run's output.
+// The profile starts when the main package is initialized and stops
+// when main returns or, in a test program, in an example named
+// profileExample after the tests and examples; see addProfiling.
+//
+// Programs run with a fake clock: time.Sleep and timers advance it
+// without waiting. So a CPU profile only shows the time spent computing,
+// and its timestamps and duration are meaningless.
+const (
+	profileFile    = "profile.pprof"
+	profileExample = hiddenExamplePrefix + "Profile"
+)
+
+// maxTopFunctions is the number of functions listed in
+// profileSummary.Top.
+const maxTopFunctions = 20
+
+// checkProfile reports an error if a request asks for an unknown kind
+// of profile.
+func checkProfile(kind string) error {
+	switch kind {
+	case "", "cpu", "heap":
+		return nil
+	}
+	return fmt.Errorf("unknown profile %q", kind)
+}
+
+// profileSummary is a summary of the profile of a run, for display.
+type profileSummary struct {
+	// Kind is "cpu" or "heap". The values of a CPU profile are the
+	// time spent computing, and those of a heap profile the bytes
+	// allocated.
+	Kind  string `json:"kind"`
+	Unit  string `json:"unit"` // "nanoseconds" or "bytes"
+	Total int64  `json:"total"`
+	// Top are the functions with the most flat value, at most
+	// maxTopFunctions of them.
+	Top []profileFunc `json:"top"`
+	// Flame is the call tree of the samples, named "root", in the
+	// format of d3-flame-graph.
+	Flame *flameNode `json:"flame"`
+}
+
+// profileFunc is the value of a function in a profile.
+type profileFunc struct {
+	Name string `json:"name"`
+	// File and Line are the position of the function, if it is in
+	// the snippet. File is relative to the snippet root.
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	// Flat is the value of the function itself, and Cum that of the
+	// function and the functions it calls.
+	Flat int64 `json:"flat"`
+	Cum  int64 `json:"cum"`
+}
+
+// flameNode is a node of the call tree of a profile.
+type flameNode struct {
+	Name     string       `json:"name"`
+	Value    int64        `json:"value"`
+	Children []*flameNode `json:"children,omitempty"`
+}
+
+// addProfiling adds code to the program in files, built with the
+// package argument pkgArg, to write the profile and execution trace
+// requested by opt. The main function of a program is renamed, to stop
+// profiling when it returns, so programs that exit with os.Exit write
+// no profile. Test programs, as returned by getTestProg with a profile
+// or trace option, stop it in profileExample instead.
+//
+// Line numbers are kept, so compile errors refer to the user's code.
+// The program is left alone if it cannot be parsed, so that the build
+// reports why.
+func addProfiling(files *fileSet, pkgArg string, opt *buildOptions, test bool) {
+	dir := "."
+	if pkgArg != progName {
+		dir = path.Clean(pkgArg)
+	}
+	for _, name := range files.files {
+		if path.Dir(name) != dir || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		src := files.Data(name)
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			return
+		}
+		var mainFunc *ast.FuncDecl
+		for _, d := range f.Decls {
+			if fn, ok := d.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+				mainFunc = fn
+			}
+		}
+		if mainFunc == nil {
+			continue
+		}
+		var code bytes.Buffer
+		if test {
+			code.Write(src)
+		} else {
+			off := fset.Position(mainFunc.Name.Pos()).Offset
+			code.Write(src[:off])
+			code.WriteString("playgroundMain")
+			code.Write(src[off+len("main"):])
+		}
+		data := struct {
+			Kind, DirEnv, File, TraceFile string
+			Trace, Test                   bool
+		}{opt.profile, sandboxtypes.OutputDirEnv, profileFile, traceFile, opt.trace, test}
+		if err := profileTmpl.Execute(&code, data); err != nil {
+			panic(err)
+		}
+		// Add the imports after the package clause, on the same line.
+		imports := `;import (playgroundOS "os"; playgroundFilepath "path/filepath"`
+		if opt.profile != "" {
+			imports += `; playgroundPprof "runtime/pprof"`
+		}
+		if opt.profile == "heap" {
+			imports += `; playgroundRuntime "runtime"`
+		}
+		if opt.trace {
+			imports += `; playgroundTrace "runtime/trace"`
+		}
+		imports += ")"
+		importPos := fset.Position(f.Name.End()).Offset
+		out := code.Bytes()
+		files.AddFile(name, bytes.Join([][]byte{out[:importPos], []byte(imports), out[importPos:]}, nil))
+		return
+	}
+}
+
+var profileTmpl = template.Must(template.New("profile").Parse(`
+{{- if .Kind}}
+var playgroundProfileFile *playgroundOS.File
+{{- end}}
+{{- if .Trace}}
+var playgroundTraceFile *playgroundOS.File
+{{- end}}
+
+func init() {
+	dir := playgroundOS.Getenv({{printf "%q" .DirEnv}})
+{{- if .Kind}}
+	f, err := playgroundOS.Create(playgroundFilepath.Join(dir, {{printf "%q" .File}}))
+	if err != nil {
+		panic(err)
+	}
+	playgroundProfileFile = f
+{{- if eq .Kind "cpu"}}
+	if err := playgroundPprof.StartCPUProfile(f); err != nil {
+		panic(err)
+	}
+{{- else}}
+	playgroundRuntime.MemProfileRate = 1
+{{- end}}
+{{- end}}
+{{- if .Trace}}
+	tf, err := playgroundOS.Create(playgroundFilepath.Join(dir, {{printf "%q" .TraceFile}}))
+	if err != nil {
+		panic(err)
+	}
+	playgroundTraceFile = tf
+	if err := playgroundTrace.Start(tf); err != nil {
+		panic(err)
+	}
+{{- end}}
+}
+
+func playgroundStopProfile() {
+{{- if .Trace}}
+	playgroundTrace.Stop()
+	playgroundTraceFile.Close()
+{{- end}}
+{{- if eq .Kind "cpu"}}
+	playgroundPprof.StopCPUProfile()
+	playgroundProfileFile.Close()
+{{- else if eq .Kind "heap"}}
+	playgroundRuntime.GC()
+	playgroundPprof.Lookup("allocs").WriteTo(playgroundProfileFile, 0)
+	playgroundProfileFile.Close()
+{{- end}}
+}
+{{if not .Test}}
+func main() {
+	playgroundMain()
+	playgroundStopProfile()
+}
+{{end}}`))
+
+// summarizeProfile summarizes the profile data of a program built in
+// dir. It returns nil if there is no data, as when the program exited
+// before writing it.
+func summarizeProfile(data []byte, dir string) (*profileSummary, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	p, err := profile.ParseData(data)
+	if err != nil {
+		return nil, err
+	}
+	// Use the CPU time of a CPU profile, and the bytes allocated of a
+	// heap profile.
+	sum := &profileSummary{Flame: &flameNode{Name: "root"}}
+	index := -1
+	for i, st := range p.SampleType {
+		switch {
+		case st.Type == "cpu" && st.Unit == "nanoseconds":
+			sum.Kind, sum.Unit, index = "cpu", st.Unit, i
+		case st.Type == "alloc_space" && st.Unit == "bytes":
+			sum.Kind, sum.Unit, index = "heap", st.Unit, i
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("profile has no CPU time or allocated bytes")
+	}
+
+	funcs := map[string]*profileFunc{}
+	for _, s := range p.Sample {
+		v := s.Value[index]
+		if v == 0 {
+			continue
+		}
+		sum.Total += v
+		sum.Flame.Value += v
+		// The stack, from the leaf to the root; inlined calls are
+		// listed first in their location.
+		var stack []*profile.Function
+		for _, loc := range s.Location {
+			for _, line := range loc.Line {
+				fn := line.Function
+				if fn == nil {
+					continue
+				}
+				// Show the user's main function, renamed by
+				// addProfiling, instead of the one calling it.
+				if n := len(stack); fn.Name == "main.main" && n > 0 && stack[n-1].Name == "main.playgroundMain" {
+					continue
+				}
+				stack = append(stack, fn)
+			}
+		}
+		seen := map[string]bool{}
+		for i, fn := range stack {
+			name := profileFuncName(fn)
+			pf := funcs[name]
+			if pf == nil {
+				pf = &profileFunc{Name: name}
+				if file := relativeFile(fn.Filename, dir); fn.Filename != "" && !filepath.IsAbs(file) {
+					pf.File, pf.Line = file, int(fn.StartLine)
+				}
+				funcs[name] = pf
+			}
+			if i == 0 {
+				pf.Flat += v
+			}
+			if !seen[name] {
+				seen[name] = true
+				pf.Cum += v
+			}
+		}
+		node := sum.Flame
+		for i := len(stack) - 1; i >= 0; i-- {
+			node = node.child(profileFuncName(stack[i]))
+			node.Value += v
+		}
+	}
+
+	for _, pf := range funcs {
+		if pf.Flat > 0 {
+			sum.Top = append(sum.Top, *pf)
+		}
+	}
+	sort.Slice(sum.Top, func(i, j int) bool {
+		a, b := sum.Top[i], sum.Top[j]
+		if a.Flat != b.Flat {
+			return a.Flat > b.Flat
+		}
+		if a.Cum != b.Cum {
+			return a.Cum > b.Cum
+		}
+		return a.Name < b.Name
+	})
+	if len(sum.Top) > maxTopFunctions {
+		sum.Top = sum.Top[:maxTopFunctions]
+	}
+	sum.Flame.sort()
+	return sum, nil
+}
+
+// profileFuncName returns the name of fn to display.
+func profileFuncName(fn *profile.Function) string {
+	if fn.Name == "main.playgroundMain" {
+		return "main.main"
+	}
+	return fn.Name
+}
+
+// child returns the child of n with the given name, adding it if
+// needed.
+func (n *flameNode) child(name string) *flameNode {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	c := &flameNode{Name: name}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// sort sorts the children of n and its descendants by name.
+func (n *flameNode) sort() {
+	sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Name < n.Children[j].Name })
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
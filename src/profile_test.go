@@ -0,0 +1,203 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/playground/sandbox/sandboxtypes"
+)
+
+// runProfiled builds the program in the txtar archive src with the
+// local go command, profiled as in sandboxBuild, runs it and summarizes
+// its profile.
+func runProfiled(t *testing.T, src, kind string) *profileSummary {
+	t.Helper()
+	dir, outDir := runWithOutput(t, src, &buildOptions{profile: kind})
+	data, err := os.ReadFile(filepath.Join(outDir, profileFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := summarizeProfile(data, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sum
+}
+
+// runWithOutput builds the program in the txtar archive src with the
+// local go command, profiled and traced as requested by opt, and runs
+// it. It returns the build directory and the output directory, where
+// the program wrote its files.
+func runWithOutput(t *testing.T, src string, opt *buildOptions) (dir, outDir string) {
+	t.Helper()
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	files, err := splitFiles([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgArg, testParam := ".", ""
+	if files.Num() == 1 {
+		pkgArg = progName
+		if code := getTestProg(files.Data(progName), opt); code != nil {
+			files.AddFile(progName, code)
+			testParam = "-test.v"
+		}
+	}
+	files.AddFile("go.mod", []byte("module play\n"))
+	addProfiling(files, pkgArg, opt, testParam != "")
+
+	dir = t.TempDir()
+	for name, data := range files.m {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	build := exec.Command(goBin, "build", "-o", "a.out", pkgArg)
+	build.Dir = dir
+	build.Env = append(os.Environ(), "GOFLAGS=")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	outDir = t.TempDir()
+	run := exec.Command(filepath.Join(dir, "a.out"))
+	if testParam != "" {
+		run.Args = append(run.Args, testParam)
+	}
+	run.Env = append(os.Environ(), sandboxtypes.OutputDirEnv+"="+outDir)
+	if out, err := run.CombinedOutput(); err != nil {
+		t.Fatalf("running program: %v\n%s", err, out)
+	}
+	return dir, outDir
+}
+
+// topFunc returns the function named name in sum.Top, or nil.
+func topFunc(sum *profileSummary, name string) *profileFunc {
+	for i := range sum.Top {
+		if sum.Top[i].Name == name {
+			return &sum.Top[i]
+		}
+	}
+	return nil
+}
+
+func TestHeapProfile(t *testing.T) {
+	for _, tt := range []struct {
+		name, src string
+		wantLine  int
+	}{
+		{
+			name: "program",
+			src: `package main
+
+var sink []byte
+
+func alloc() {
+	sink = make([]byte, 1<<20)
+}
+
+func main() {
+	alloc()
+}
+`,
+			wantLine: 5,
+		},
+		{
+			name: "test",
+			src: `package main
+
+import "testing"
+
+var sink []byte
+
+func alloc() {
+	sink = make([]byte, 1<<20)
+}
+
+func TestAlloc(t *testing.T) {
+	alloc()
+}
+`,
+			wantLine: 7,
+		},
+		{
+			name: "multiple files",
+			src: `-- main.go --
+package main
+
+func main() {
+	alloc()
+}
+-- alloc.go --
+package main
+
+var sink []byte
+
+func alloc() {
+	sink = make([]byte, 1<<20)
+}
+`,
+			wantLine: 5,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sum := runProfiled(t, tt.src, "heap")
+			if sum.Kind != "heap" || sum.Unit != "bytes" {
+				t.Errorf("profile kind and unit = %q, %q; want heap, bytes", sum.Kind, sum.Unit)
+			}
+			if sum.Flame.Value != sum.Total || sum.Total < 1<<20 {
+				t.Errorf("total = %d, flame graph root = %d; want equal, at least 1 MiB", sum.Total, sum.Flame.Value)
+			}
+			f := topFunc(sum, "main.alloc")
+			if f == nil {
+				t.Fatalf("main.alloc not in top functions: %+v", sum.Top)
+			}
+			if f.Flat < 1<<20 || f.Line != tt.wantLine || filepath.Ext(f.File) != ".go" {
+				t.Errorf("main.alloc = %+v; want flat of at least 1 MiB, at line %d of a snippet file", f, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestCPUProfile(t *testing.T) {
+	sum := runProfiled(t, `package main
+
+import "fmt"
+
+func spin() int {
+	n := 0
+	for i := 0; i < 3e8; i++ {
+		n += i % 7
+	}
+	return n
+}
+
+func main() {
+	fmt.Println(spin())
+}
+`, "cpu")
+	if sum.Kind != "cpu" || sum.Unit != "nanoseconds" {
+		t.Errorf("profile kind and unit = %q, %q; want cpu, nanoseconds", sum.Kind, sum.Unit)
+	}
+	if len(sum.Top) == 0 {
+		t.Skip("no CPU samples")
+	}
+	if f := topFunc(sum, "main.main"); f == nil && topFunc(sum, "main.spin") == nil {
+		t.Errorf("neither main.main nor main.spin in top functions: %+v", sum.Top)
+	}
+	for _, c := range sum.Flame.Children {
+		for _, c := range c.Children {
+			if c.Name == "main.playgroundMain" {
+				t.Errorf("flame graph shows the renamed main function")
+			}
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// Rate limit budgets. Each client has a separate budget for each.
+const (
+	budgetCompile = "compile" // /compile and the other endpoints that build programs
+	budgetFmt     = "fmt"
+	budgetShare   = "share"
+	budgetVet     = "vet"
+	budgetPoll    = "poll" // GET and DELETE /jobs/{id}
+)
+
+// rateLimit is the budget of one client for one kind of request:
+// burst requests at once, refilled at rate requests per second.
+type rateLimit struct {
+	rate  float64
+	burst float64
+}
+
+// parseRateLimits parses a comma-separated list of budget=N/unit
+// limits, such as "compile=60/m,fmt=120/m". The unit is one of s, m or h.
+// A client may make N requests at once, and then N per unit.
+func parseRateLimits(s string) (map[string]rateLimit, error) {
+	limits := map[string]rateLimit{}
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(f, "=")
+		if !ok {
+			return nil, fmt.Errorf("rate limit %q: missing =", f)
+		}
+		count, unit, ok := strings.Cut(spec, "/")
+		if !ok {
+			return nil, fmt.Errorf("rate limit %q: missing /", f)
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("rate limit %q: bad count %q", f, count)
+		}
+		var per time.Duration
+		switch unit {
+		case "s":
+			per = time.Second
+		case "m":
+			per = time.Minute
+		case "h":
+			per = time.Hour
+		default:
+			return nil, fmt.Errorf("rate limit %q: bad unit %q", f, unit)
+		}
+		limits[name] = rateLimit{rate: float64(n) / per.Seconds(), burst: float64(n)}
+	}
+	return limits, nil
+}
+
+// readAPITokens reads the API tokens in file, one per line. Empty
+// lines and lines starting with # are ignored.
+func readAPITokens(file string) (map[string]bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tokens := map[string]bool{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens[line] = true
+	}
+	return tokens, sc.Err()
+}
+
+// rateLimiter limits the rate of requests of each client with a token
+// bucket per client and budget. Clients are identified by their API
+// token if they send one, and by their IP address otherwise.
+type rateLimiter struct {
+	limits map[string]rateLimit // budget -> limit; budgets not in it are unlimited
+	// proxyHeader, if set, is the header holding the client address
+	// set by a trusted reverse proxy, as in X-Forwarded-For.
+	proxyHeader string
+	tokens      map[string]bool // valid API tokens
+	now         func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*tokenBucket
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	budget, client string
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+const (
+	// rateLimitSweepPeriod is how often idle buckets are dropped.
+	rateLimitSweepPeriod = time.Minute
+	// maxRateLimitBuckets bounds the number of buckets, so that a
+	// flood of clients cannot grow them without bound until the next
+	// sweep. Past it, an arbitrary bucket is dropped for each new
+	// one, which at worst grants its client a fresh budget.
+	maxRateLimitBuckets = 100000
+)
+
+func newRateLimiter(limits map[string]rateLimit, proxyHeader string, tokens map[string]bool) *rateLimiter {
+	return &rateLimiter{
+		limits:      limits,
+		proxyHeader: proxyHeader,
+		tokens:      tokens,
+		now:         time.Now,
+		buckets:     map[bucketKey]*tokenBucket{},
+	}
+}
+
+// allow reports whether client may make a request against budget now,
+// and if not, how long it should wait before retrying.
+func (l *rateLimiter) allow(budget, client string) (ok bool, retryAfter time.Duration) {
+	lim, limited := l.limits[budget]
+	if !limited {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastSweep) > rateLimitSweepPeriod {
+		l.sweep(now)
+	}
+	k := bucketKey{budget, client}
+	b := l.buckets[k]
+	if b == nil {
+		if len(l.buckets) >= maxRateLimitBuckets {
+			for k := range l.buckets {
+				delete(l.buckets, k)
+				break
+			}
+		}
+		b = &tokenBucket{tokens: lim.burst, last: now}
+		l.buckets[k] = b
+	}
+	b.tokens = math.Min(lim.burst, b.tokens+now.Sub(b.last).Seconds()*lim.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / lim.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops the buckets that have refilled, since a new bucket is
+// equivalent. l.mu must be held.
+func (l *rateLimiter) sweep(now time.Time) {
+	for k, b := range l.buckets {
+		lim := l.limits[k.budget]
+		if b.tokens+now.Sub(b.last).Seconds()*lim.rate >= lim.burst {
+			delete(l.buckets, k)
+		}
+	}
+	l.lastSweep = now
+}
+
+// client returns the key identifying the client that sent r.
+// It reports an error if r carries an unknown API token.
+func (l *rateLimiter) client(r *http.Request) (string, error) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		tok := strings.TrimPrefix(auth, "Bearer ")
+		if tok == auth || !l.tokens[tok] {
+			return "", fmt.Errorf("invalid API token")
+		}
+		return "token:" + tok, nil
+	}
+	if l.proxyHeader != "" {
+		if v := r.Header.Get(l.proxyHeader); v != "" {
+			// The trusted proxy appends the address it saw
+			// to whatever the client sent.
+			if i := strings.LastIndex(v, ","); i >= 0 {
+				v = v[i+1:]
+			}
+			return "ip:" + strings.TrimSpace(v), nil
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host, nil
+}
+
+// limit returns a handler that serves requests with h as long as
+// the client is within budget, and with a 429 error otherwise.
+// CORS pre-flight requests are not counted.
+// If s has no rateLimiter, h is returned.
+func (s *server) limit(budget string, h http.Handler) http.Handler {
+	if s.limiter == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		ok, retryAfter, err := s.limiter.check(r.Context(), budget, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, "Too many requests; try again later.", http.StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// check is allow for the client that sent r, recording the outcome
+// in the rate limit metrics.
+func (l *rateLimiter) check(ctx context.Context, budget string, r *http.Request) (ok bool, retryAfter time.Duration, err error) {
+	client, err := l.client(r)
+	if err != nil {
+		recordRateLimit(ctx, budget, "unauthorized")
+		return false, 0, err
+	}
+	ok, retryAfter = l.allow(budget, client)
+	recordRateLimit(ctx, budget, rateLimitOutcome(ok))
+	return ok, retryAfter, nil
+}
+
+func rateLimitOutcome(ok bool) string {
+	if ok {
+		return "allowed"
+	}
+	return "limited"
+}
+
+// recordRateLimit records the outcome of a rate limited request.
+func recordRateLimit(ctx context.Context, budget, outcome string) {
+	// Ignore error. The only error can be invalid tag key or value
+	// length, which we know are safe.
+	stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(kRateLimitBudget, budget), tag.Upsert(kRateLimitOutcome, outcome)},
+		mRateLimitRequests.M(1))
+}
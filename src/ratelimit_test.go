@@ -0,0 +1,122 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	const limits = "compile=60/m,fmt=120/m,share=30/m,vet=60/m"
+	got, err := parseRateLimits(limits)
+	if err != nil {
+		t.Fatalf("parseRateLimits(%q): %v", limits, err)
+	}
+	want := map[string]rateLimit{
+		"compile": {rate: 1, burst: 60},
+		"fmt":     {rate: 2, burst: 120},
+		"share":   {rate: 0.5, burst: 30},
+		"vet":     {rate: 1, burst: 60},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(rateLimit{})); diff != "" {
+		t.Errorf("parseRateLimits mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, bad := range []string{"compile", "compile=60", "compile=x/m", "compile=0/m", "compile=60/d"} {
+		if _, err := parseRateLimits(bad); err == nil {
+			t.Errorf("parseRateLimits(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limits, _ := parseRateLimits("compile=2/s")
+	l := newRateLimiter(limits, "X-Forwarded-For", map[string]bool{"secret": true})
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("compile", "a"); !ok {
+			t.Fatalf("request %d within burst was limited", i)
+		}
+	}
+	if ok, retry := l.allow("compile", "a"); ok || retry != 500*time.Millisecond {
+		t.Errorf("request over burst: got (%v, %v), want (false, 500ms)", ok, retry)
+	}
+	if ok, _ := l.allow("compile", "b"); !ok {
+		t.Errorf("other client was limited")
+	}
+	if ok, _ := l.allow("fmt", "a"); !ok {
+		t.Errorf("unlimited budget was limited")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := l.allow("compile", "a"); !ok {
+		t.Errorf("request after refill was limited")
+	}
+
+	// Full buckets are dropped.
+	now = now.Add(time.Hour)
+	l.allow("compile", "c")
+	if len(l.buckets) != 1 {
+		t.Errorf("after sweep: got %d buckets, want 1", len(l.buckets))
+	}
+
+	// The buckets are bounded even before they are swept.
+	for i := 0; i <= maxRateLimitBuckets; i++ {
+		l.allow("compile", fmt.Sprint("flood", i))
+	}
+	if len(l.buckets) != maxRateLimitBuckets {
+		t.Errorf("after a flood of clients: got %d buckets, want %d", len(l.buckets), maxRateLimitBuckets)
+	}
+
+	for _, tc := range []struct {
+		header  http.Header
+		want    string
+		wantErr bool
+	}{
+		{nil, "ip:192.0.2.1", false},
+		{http.Header{"X-Forwarded-For": {"10.0.0.1, 198.51.100.7"}}, "ip:198.51.100.7", false},
+		{http.Header{"Authorization": {"Bearer secret"}}, "token:secret", false},
+		{http.Header{"Authorization": {"Bearer guess"}}, "", true},
+	} {
+		r := httptest.NewRequest("POST", "/compile", nil)
+		r.Header = tc.header
+		if r.Header == nil {
+			r.Header = http.Header{}
+		}
+		got, err := l.client(r)
+		if got != tc.want || (err != nil) != tc.wantErr {
+			t.Errorf("client with header %v = %q, %v; want %q, error %v", tc.header, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestLimitHandler(t *testing.T) {
+	limits, _ := parseRateLimits("share=1/m")
+	s := &server{limiter: newRateLimiter(limits, "", nil)}
+	h := s.limit(budgetShare, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/share", nil))
+		if w.Code != want {
+			t.Errorf("request %d: got status %d, want %d", i, w.Code, want)
+		}
+		if want == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "60" {
+			t.Errorf("request %d: got Retry-After %q, want 60", i, w.Header().Get("Retry-After"))
+		}
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/share", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("pre-flight request: got status %d, want %d", w.Code, http.StatusOK)
+	}
+}
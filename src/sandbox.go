@@ -24,6 +24,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
@@ -40,11 +41,14 @@ import (
 	"golang.org/x/playground/sandbox/sandboxtypes"
 )
 
-const (
-	// Time for 'go build' to download 3rd-party modules and compile.
+// Time for 'go build' to download 3rd-party modules and compile, and
+// to run the program. They are set in main from the configuration.
+var (
 	maxBuildTime = 10 * time.Second
 	maxRunTime   = 5 * time.Second
+)
 
+const (
 	// progName is the implicit program name written to the temp
 	// dir and used in compiler and vet errors.
 	progName = "prog.go"
@@ -63,8 +67,41 @@ var internalErrors = []string{
 }
 
 type request struct {
-	Body    string
-	WithVet bool // whether client supports vet response in a /compile request (Issue 31970)
+	Body      string
+	WithVet   bool     // whether client supports vet response in a /compile request (Issue 31970)
+	Func      string   // function to dump the SSA of, in a /ssa request
+	Analyzers []string // extra analyzers to run in a /compile or /build request
+	// WithCoverage requests the test coverage of a /compile request
+	// whose program is a test; see response.Coverage.
+	WithCoverage bool
+	// Profile is "cpu" or "heap" to profile the program of a /compile
+	// request; see response.Profile.
+	Profile string
+	// Trace requests the execution trace of the program of a /compile
+	// request; see response.Trace.
+	Trace bool
+}
+
+// cacheBody returns the part of the cache key that identifies r.
+// It is r.Body unless options that change the response are set.
+func (r *request) cacheBody() string {
+	body := r.Body
+	if r.Func != "" {
+		body += "\x00func=" + r.Func
+	}
+	if len(r.Analyzers) > 0 {
+		body += "\x00analyzers=" + strings.Join(r.Analyzers, ",")
+	}
+	if r.WithCoverage {
+		body += "\x00coverage"
+	}
+	if r.Profile != "" {
+		body += "\x00profile=" + r.Profile
+	}
+	if r.Trace {
+		body += "\x00trace"
+	}
+	return body
 }
 
 type response struct {
@@ -81,6 +118,62 @@ type response struct {
 	// populated if request.WithVet was true. Only one of
 	// VetErrors or VetOK can be non-zero.
 	VetOK bool `json:",omitempty"`
+	// VetDiagnostics, if non-empty, contains the issues listed in
+	// VetErrors, with their suggested fixes, which the /fix endpoint
+	// applies. It is only populated if request.WithVet was true and
+	// vet could check the program.
+	VetDiagnostics []diagnostic `json:",omitempty"`
+
+	// CompileErrors, if non-empty, contains the build failures
+	// of the program, one per diagnostic. It is only populated
+	// by the /build endpoint.
+	CompileErrors []compileError `json:",omitempty"`
+
+	// Asm, if non-empty, contains the assembly generated for the
+	// user's packages. It is only populated by the /asm endpoint.
+	Asm []asmFunc `json:",omitempty"`
+
+	// Annotations, if non-empty, contains the compiler's escape
+	// analysis and inlining decisions for the user's files. It is
+	// only populated by the /escape endpoint.
+	Annotations []annotation `json:",omitempty"`
+
+	// SSA, if non-empty, is the ssa.html page written by the
+	// compiler for request.Func. It is only populated by the /ssa
+	// endpoint.
+	SSA string `json:",omitempty"`
+
+	// Diagnostics, if non-empty, contains the findings of the
+	// analyzers in request.Analyzers. It is only populated by the
+	// /compile and /build endpoints.
+	Diagnostics []diagnostic `json:",omitempty"`
+
+	// Vulns, if non-empty, contains the known vulnerabilities of the
+	// modules the program uses. It is only populated by the
+	// /vulncheck endpoint.
+	Vulns []vulnFinding `json:",omitempty"`
+
+	// Coverage, if non-empty, contains the lines of the program
+	// covered and not covered by its tests and examples. It is only
+	// populated if request.WithCoverage was true and the program is
+	// a test.
+	Coverage []fileCoverage `json:",omitempty"`
+
+	// Profile, if non-nil, summarizes the profile of the program
+	// requested with request.Profile. It is nil if the program did
+	// not write the profile, as when it exits with os.Exit.
+	Profile *profileSummary `json:",omitempty"`
+
+	// Trace, if non-nil, is the timeline of the goroutines of the
+	// program, from its execution trace requested with request.Trace.
+	// It is nil if the program did not write the trace.
+	Trace *traceTimeline `json:",omitempty"`
+
+	// Usage, if non-nil, is the resources the program used when it
+	// ran in the sandbox: its wall and CPU time, peak memory and
+	// output size. A cached response reports those of the run that
+	// was cached.
+	Usage *sandboxtypes.Usage `json:",omitempty"`
 }
 
 // commandHandler returns an http.HandlerFunc.
@@ -98,13 +191,8 @@ func (s *server) commandHandler(cachePrefix string, cmdFunc func(context.Context
 			return
 		}
 
-		var req request
-		// Until programs that depend on golang.org/x/tools/godoc/static/playground.js
-		// are updated to always send JSON, this check is in place.
-		if b := r.FormValue("body"); b != "" {
-			req.Body = b
-			req.WithVet, _ = strconv.ParseBool(r.FormValue("withVet"))
-		} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		req, err := decodeRequest(r)
+		if err != nil {
 			s.log.Errorf("error decoding request: %v", err)
 			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 			return
@@ -115,18 +203,35 @@ func (s *server) commandHandler(cachePrefix string, cmdFunc func(context.Context
 		}
 
 		resp := &response{}
-		key := cacheKey(cachePrefix, req.Body)
+		key := cacheKey(cachePrefix, req.cacheBody())
 		if err := s.cache.Get(key, resp); err != nil {
 			if !errors.Is(err, memcache.ErrCacheMiss) {
 				s.log.Errorf("s.cache.Get(%q, &response): %v", key, err)
 			}
-			resp, err = cmdFunc(r.Context(), &req)
+			resp, err = cmdFunc(r.Context(), req)
+			if cerr := r.Context().Err(); cerr != nil {
+				// The client went away, for example because the
+				// user killed the program; cmdFunc stopped early
+				// and nobody is waiting for its result.
+				s.log.Printf("cmdFunc canceled: %v", cerr)
+				return
+			}
+			if errors.Is(err, errBuildQueueFull) {
+				w.Header().Set("Retry-After", strconv.Itoa(int(buildRetryAfter.Seconds())))
+				http.Error(w, "Too many programs are being built; try again later.", http.StatusTooManyRequests)
+				return
+			}
 			if err != nil {
 				s.log.Errorf("cmdFunc error: %v", err)
 				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 				return
 			}
-			if strings.Contains(resp.Errors, goBuildTimeoutError) || strings.Contains(resp.Errors, runTimeoutError) {
+			cacheable, err := s.checkResponse(resp)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if !cacheable {
 				// TODO(golang.org/issue/38576) - This should be a http.StatusBadRequest,
 				// but the UI requires a 200 to parse the response. It's difficult to know
 				// if we've timed out because of an error in the code snippet, or instability
@@ -135,25 +240,6 @@ func (s *server) commandHandler(cachePrefix string, cmdFunc func(context.Context
 				s.writeJSONResponse(w, resp, http.StatusOK)
 				return
 			}
-			for _, e := range internalErrors {
-				if strings.Contains(resp.Errors, e) {
-					s.log.Errorf("cmdFunc compilation error: %q", resp.Errors)
-					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-					return
-				}
-			}
-			for _, el := range resp.Events {
-				if el.Kind != "stderr" {
-					continue
-				}
-				for _, e := range internalErrors {
-					if strings.Contains(el.Message, e) {
-						s.log.Errorf("cmdFunc runtime error: %q", el.Message)
-						http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-						return
-					}
-				}
-			}
 			if err := s.cache.Set(key, resp); err != nil {
 				s.log.Errorf("cache.Set(%q, resp): %v", key, err)
 			}
@@ -163,6 +249,61 @@ func (s *server) commandHandler(cachePrefix string, cmdFunc func(context.Context
 	}
 }
 
+// decodeRequest returns the *request sent to a command endpoint.
+func decodeRequest(r *http.Request) (*request, error) {
+	var req request
+	// Until programs that depend on golang.org/x/tools/godoc/static/playground.js
+	// are updated to always send JSON, this check is in place.
+	if b := r.FormValue("body"); b != "" {
+		req.Body = b
+		req.WithVet, _ = strconv.ParseBool(r.FormValue("withVet"))
+		req.Func = r.FormValue("func")
+		if a := r.FormValue("analyzers"); a != "" {
+			req.Analyzers = strings.Split(a, ",")
+		}
+		req.WithCoverage, _ = strconv.ParseBool(r.FormValue("withCoverage"))
+		req.Profile = r.FormValue("profile")
+		req.Trace, _ = strconv.ParseBool(r.FormValue("trace"))
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+	if err := checkAnalyzers(req.Analyzers); err != nil {
+		return nil, err
+	}
+	if err := checkProfile(req.Profile); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// checkResponse reports whether resp, as returned by a command, may be
+// cached. Timeouts are not cached, since they may be caused by load on
+// the playground rather than by the program. A non-nil error means
+// resp contains an internal error that must not be shown to the user.
+func (s *server) checkResponse(resp *response) (cacheable bool, err error) {
+	if strings.Contains(resp.Errors, goBuildTimeoutError) || strings.Contains(resp.Errors, runTimeoutError) {
+		return false, nil
+	}
+	for _, e := range internalErrors {
+		if strings.Contains(resp.Errors, e) {
+			s.log.Errorf("cmdFunc compilation error: %q", resp.Errors)
+			return false, fmt.Errorf("internal compilation error: %q", e)
+		}
+	}
+	for _, el := range resp.Events {
+		if el.Kind != "stderr" {
+			continue
+		}
+		for _, e := range internalErrors {
+			if strings.Contains(el.Message, e) {
+				s.log.Errorf("cmdFunc runtime error: %q", el.Message)
+				return false, fmt.Errorf("internal runtime error: %q", e)
+			}
+		}
+	}
+	return true, nil
+}
+
 func cacheKey(prefix, body string) string {
 	h := sha256.New()
 	io.WriteString(h, body)
@@ -211,7 +352,10 @@ func isTest(name, prefix string) bool {
 // If the main function is present or there are no tests or examples, it returns nil.
 // getTestProg emulates the "go test" command as closely as possible.
 // Benchmarks are not supported because of sandboxing.
-func getTestProg(src []byte) []byte {
+// If opt.coverage is set, the program also writes the coverage profile
+// of src, once instrumented by instrumentCoverage; if opt.profile is
+// set, it stops the profile added by addProfiling.
+func getTestProg(src []byte, opt *buildOptions) []byte {
 	fset := token.NewFileSet()
 	// Early bail for most cases.
 	f, err := parser.ParseFile(fset, progName, src, parser.ImportsOnly)
@@ -281,19 +425,47 @@ func getTestProg(src []byte) []byte {
 		return nil
 	}
 
+	var imports []string
 	if !testingImported && (len(ex) > 0 || exNoOutput) {
 		// In case of the program with examples and no "testing" package imported,
 		// add import after "package main" without modifying line numbers.
-		importDecl := []byte(`;import "testing";`)
+		imports = append(imports, `"testing"`)
+	}
+	if opt.coverage {
+		// Named so as not to conflict with the user's imports, nor
+		// with those of addProfiling.
+		imports = append(imports, `playgroundCoverOS "os"`, `playgroundCoverFilepath "path/filepath"`, `playgroundStrconv "strconv"`)
+	}
+	if len(imports) > 0 {
+		importDecl := []byte(";import (" + strings.Join(imports, ";") + ");")
 		src = bytes.Join([][]byte{src[:importPos], importDecl, src[importPos:]}, nil)
 	}
 
 	data := struct {
 		Tests    []string
 		Examples []*doc.Example
+		Coverage bool
+		CoverVar string
+		// CoverExample is the name of the example that writes the
+		// coverage profile to CoverFile in the directory named by
+		// DirEnv, after the tests and examples have run.
+		CoverExample      string
+		DirEnv, CoverFile string
+		// Profile adds an example named ProfileExample that
+		// stops profiling and tracing, before the one writing the
+		// coverage.
+		Profile        bool
+		ProfileExample string
 	}{
 		tests,
 		ex,
+		opt.coverage,
+		coverVar,
+		coverExample,
+		sandboxtypes.OutputDirEnv,
+		coverFile,
+		opt.profile != "" || opt.trace,
+		profileExample,
 	}
 	code := new(bytes.Buffer)
 	if err := testTmpl.Execute(code, data); err != nil {
@@ -314,20 +486,162 @@ func main() {
 	examples := []testing.InternalExample{
 {{range .Examples}}
 		{"Example{{.Name}}", Example{{.Name}}, {{printf "%q" .Output}}, {{.Unordered}}},
+{{end}}
+{{if .Profile}}
+		{ {{- printf "%q" .ProfileExample}}, playgroundStopProfile, "", false},
+{{end}}
+{{if .Coverage}}
+		{ {{- printf "%q" .CoverExample}}, playgroundWriteCoverage, "", false},
 {{end}}
 	}
 	testing.Main(matchAll, tests, nil, examples)
 }
+{{if .Coverage}}
+// playgroundWriteCoverage writes the coverage profile to the output
+// directory.
+func playgroundWriteCoverage() {
+	b := []byte("mode: count\n")
+	for i, n := range {{.CoverVar}}.NumStmt {
+		p := {{.CoverVar}}.Pos[3*i:]
+		b = append(b, "prog.go:"...)
+		b = playgroundStrconv.AppendUint(b, uint64(p[0]), 10)
+		b = append(b, '.')
+		b = playgroundStrconv.AppendUint(b, uint64(p[2]&0xFFFF), 10)
+		b = append(b, ',')
+		b = playgroundStrconv.AppendUint(b, uint64(p[1]), 10)
+		b = append(b, '.')
+		b = playgroundStrconv.AppendUint(b, uint64(p[2]>>16), 10)
+		b = append(b, ' ')
+		b = playgroundStrconv.AppendUint(b, uint64(n), 10)
+		b = append(b, ' ')
+		b = playgroundStrconv.AppendUint(b, uint64({{.CoverVar}}.Count[i]), 10)
+		b = append(b, '\n')
+	}
+	dir := playgroundCoverOS.Getenv({{printf "%q" .DirEnv}})
+	if err := playgroundCoverOS.WriteFile(playgroundCoverFilepath.Join(dir, {{printf "%q" .CoverFile}}), b, 0644); err != nil {
+		panic(err)
+	}
+}
+{{end}}
 `))
 
+// The examples that getTestProg adds to test programs, after the tests
+// and examples of the user, are named with hiddenExamplePrefix, which
+// the names of user examples cannot start with.
+const hiddenExamplePrefix = "playground"
+
+// hiddenExampleRE matches the lines of test output about the examples
+// added by getTestProg.
+var hiddenExampleRE = regexp.MustCompile(`(?m)^(=== RUN   |--- PASS: )` + hiddenExamplePrefix + `\w*( \(.*\))?\n`)
+
+// hidesOutput reports whether the output of the program built by b
+// contains output that stripHiddenOutput removes.
+func (b *buildResult) hidesOutput() bool {
+	return b.testParam != "" && (b.coverLines > 0 || b.profile != "" || b.trace)
+}
+
+// stripHiddenOutput returns events without the test output about the
+// examples added by getTestProg.
+func stripHiddenOutput(events []Event) []Event {
+	var rest []Event
+	for _, e := range events {
+		if e = stripHiddenEvent(e); e.Message != "" {
+			rest = append(rest, e)
+		}
+	}
+	return rest
+}
+
+// stripHiddenEvent is stripHiddenOutput for a single event.
+func stripHiddenEvent(e Event) Event {
+	if e.Kind != "stdout" {
+		return e
+	}
+	e.Message = hiddenExampleRE.ReplaceAllString(e.Message, "")
+	return e
+}
+
 var failedTestPattern = "--- FAIL"
 
+// runObserver receives progress notifications while a program is
+// built and run. A nil *runObserver, or one with nil fields, ignores them.
+type runObserver struct {
+	// status is called with "building" and "running" as the
+	// program enters each stage.
+	status func(stage string)
+	// queued, if set, is called with the build's position in the
+	// build queue while it waits for a build worker, after "building",
+	// and with 0 once the build actually starts. It is not called if
+	// a worker is free right away.
+	queued func(position int)
+	// event, if set, is called with each Event of the program's
+	// output as soon as the sandbox backend reports it, rather than
+	// only in *response.Events once the program has exited.
+	event func(Event)
+}
+
+func (o *runObserver) setStatus(stage string) {
+	if o != nil && o.status != nil {
+		o.status(stage)
+	}
+}
+
+// streaming reports whether o wants to receive the program's output
+// while it runs.
+func (o *runObserver) streaming() bool {
+	return o != nil && o.event != nil
+}
+
+// run runs the binary built by br in the sandbox, streaming its output
+// to o.event if set. The output of the examples that getTestProg adds
+// to run the tests is not streamed.
+func (o *runObserver) run(ctx context.Context, br *buildResult) (sandboxtypes.Response, error) {
+	if !o.streaming() {
+		return sandboxRun(ctx, br)
+	}
+	var (
+		dec    streamDecoder
+		decErr error
+	)
+	emit := func(evs []Event, err error) {
+		if decErr != nil {
+			return
+		}
+		if err != nil {
+			// Recorder.Events reports the error once the run
+			// is over; stop streaming garbage meanwhile.
+			decErr = err
+			return
+		}
+		for _, e := range evs {
+			if br.hidesOutput() {
+				if e = stripHiddenEvent(e); e.Message == "" {
+					continue
+				}
+			}
+			o.event(e)
+		}
+	}
+	execRes, err := sandboxRunStream(ctx, br, func(kind string, data []byte) {
+		emit(dec.Write(kind, data))
+	})
+	if err == nil {
+		emit(dec.Flush())
+	}
+	return execRes, err
+}
+
 // compileAndRun tries to build and run a user program.
 // The output of successfully ran program is returned in *response.Events.
 // If a program cannot be built or has timed out,
 // *response.Errors contains an explanation for a user.
 func compileAndRun(ctx context.Context, req *request) (*response, error) {
-	// TODO(andybons): Add semaphore to limit number of running programs at once.
+	return compileAndRunObserved(ctx, req, nil)
+}
+
+// compileAndRunObserved is like compileAndRun, but reports its
+// progress to obs.
+func compileAndRunObserved(ctx context.Context, req *request, obs *runObserver) (*response, error) {
 	tmpDir, err := ioutil.TempDir("", "sandbox")
 	if err != nil {
 		return nil, fmt.Errorf("error creating temp directory: %v", err)
@@ -335,7 +649,12 @@ func compileAndRun(ctx context.Context, req *request) (*response, error) {
 	defer os.RemoveAll(tmpDir)
 
 	log.Printf("%s: start sandboxBuild", tmpDir)
-	br, err := sandboxBuild(ctx, tmpDir, []byte(req.Body), req.WithVet)
+	obs.setStatus("building")
+	var queued func(int)
+	if obs != nil {
+		queued = obs.queued
+	}
+	br, err := sandboxBuild(ctx, tmpDir, []byte(req.Body), &buildOptions{queued: queued, coverage: req.WithCoverage, profile: req.Profile, trace: req.Trace})
 	if err != nil {
 		log.Printf("%s: error sandboxBuild: %v", tmpDir, err)
 		return nil, err
@@ -344,16 +663,46 @@ func compileAndRun(ctx context.Context, req *request) (*response, error) {
 		log.Printf("%s: error sandboxBuild build result: %v", tmpDir, br.errorMessage)
 		return &response{Errors: br.errorMessage}, nil
 	}
+	defer br.cleanup()
+
+	// Vet and analyze the program while it runs.
+	waitVet := func() ([]diagnostic, string, error) { return nil, "", nil }
+	if req.WithVet {
+		waitVet = br.startVet(ctx, tmpDir)
+		// Wait for vet before the deferred cleanups, even if the
+		// run fails.
+		defer waitVet()
+	}
+	waitAnalysis := func() ([]diagnostic, error) { return nil, nil }
+	if len(req.Analyzers) > 0 {
+		waitAnalysis = br.startAnalysis(ctx, tmpDir, req.Analyzers)
+		defer waitAnalysis()
+	}
 
 	log.Printf("%s: start sandboxRun", tmpDir)
-	execRes, err := sandboxRun(ctx, br.exePath, br.testParam)
+	obs.setStatus("running")
+	execRes, err := obs.run(ctx, br)
 	if err != nil {
 		log.Printf("%s: error sandboxRun: %v", tmpDir, err)
 		return nil, err
 	}
+	vetDiags, vetOut, err := waitVet()
+	if err != nil {
+		return nil, err
+	}
+	diags, err := waitAnalysis()
+	if err != nil {
+		return nil, err
+	}
 	if execRes.Error != "" {
 		log.Printf("%s: error sandboxRun: %s", tmpDir, execRes.Error)
-		return &response{Errors: execRes.Error}, nil
+		return &response{
+			Errors:         execRes.Error,
+			VetErrors:      vetOut,
+			VetOK:          req.WithVet && vetOut == "",
+			VetDiagnostics: vetDiags,
+			Diagnostics:    diags,
+		}, nil
 	}
 
 	rec := new(Recorder)
@@ -364,6 +713,30 @@ func compileAndRun(ctx context.Context, req *request) (*response, error) {
 		log.Printf("error decoding events: %v", err)
 		return nil, fmt.Errorf("error decoding events: %v", err)
 	}
+	var coverage []fileCoverage
+	if br.coverLines > 0 {
+		coverage, err = parseCoverProfile(string(execRes.Files[coverFile]), br.coverLines)
+		if err != nil {
+			log.Printf("%s: error parsing coverage: %v", tmpDir, err)
+		}
+	}
+	var prof *profileSummary
+	if br.profile != "" {
+		prof, err = summarizeProfile(execRes.Files[profileFile], tmpDir)
+		if err != nil {
+			log.Printf("%s: error summarizing profile: %v", tmpDir, err)
+		}
+	}
+	var timeline *traceTimeline
+	if br.trace {
+		timeline, err = summarizeTrace(execRes.Files[traceFile])
+		if err != nil {
+			log.Printf("%s: error summarizing trace: %v", tmpDir, err)
+		}
+	}
+	if br.hidesOutput() {
+		events = stripHiddenOutput(events)
+	}
 	var fails int
 	if br.testParam != "" {
 		// In case of testing the TestsFailed field contains how many tests have failed.
@@ -372,12 +745,18 @@ func compileAndRun(ctx context.Context, req *request) (*response, error) {
 		}
 	}
 	return &response{
-		Events:      events,
-		Status:      execRes.ExitCode,
-		IsTest:      br.testParam != "",
-		TestsFailed: fails,
-		VetErrors:   br.vetOut,
-		VetOK:       req.WithVet && br.vetOut == "",
+		Events:         events,
+		Status:         execRes.ExitCode,
+		IsTest:         br.testParam != "",
+		TestsFailed:    fails,
+		VetErrors:      vetOut,
+		VetOK:          req.WithVet && vetOut == "",
+		VetDiagnostics: vetDiags,
+		Diagnostics:    diags,
+		Coverage:       coverage,
+		Profile:        prof,
+		Trace:          timeline,
+		Usage:          execRes.Usage,
 	}, nil
 }
 
@@ -385,15 +764,55 @@ func compileAndRun(ctx context.Context, req *request) (*response, error) {
 type buildResult struct {
 	// goPath is a temporary directory if the binary was built with module support.
 	// TODO(golang.org/issue/25224) - Why is the module mode built so differently?
+	// It is removed by cleanup once the caller is done with the build.
 	goPath string
+	// pkg is the package argument of go build.
+	pkg string
 	// exePath is the path to the built binary.
 	exePath string
 	// testParam is set if tests should be run when running the binary.
 	testParam string
+	// coverLines, if non-zero, is the number of lines of the user's
+	// test program, which is instrumented for coverage.
+	coverLines int
+	// profile is the kind of profile the program writes, if any.
+	profile string
+	// trace is whether the program writes an execution trace.
+	trace bool
+	// outputFiles are the files the program writes, which the
+	// sandbox returns in sandboxtypes.Response.Files.
+	outputFiles []string
 	// errorMessage is an error message string to be returned to the user.
 	errorMessage string
-	// vetOut is the output of go vet, if requested.
-	vetOut string
+	// compileErrors is errorMessage parsed into individual diagnostics.
+	compileErrors []compileError
+	// output is the combined output of go build. File names in it
+	// are not rewritten.
+	output []byte
+}
+
+// buildOptions controls how sandboxBuild builds a program.
+type buildOptions struct {
+	// gcflags, if non-empty, are extra compiler flags applied to
+	// the user's packages only.
+	gcflags string
+	// env holds extra environment variables for go build.
+	env []string
+	// queued, if set, is called with the build's position in the
+	// build queue while it waits for a build worker, as described
+	// for buildPool.acquire.
+	queued func(position int)
+	// toolexec, if non-empty, is the program go build runs the
+	// build tools with, as with its -toolexec flag.
+	toolexec string
+	// coverage instruments the program for coverage if it is a test.
+	coverage bool
+	// profile, if non-empty, is the kind of profile the program
+	// writes; see addProfiling.
+	profile string
+	// trace makes the program write an execution trace; see
+	// addProfiling.
+	trace bool
 }
 
 // cleanup cleans up the temporary goPath created when building with module support.
@@ -405,9 +824,10 @@ func (b *buildResult) cleanup() error {
 }
 
 // sandboxBuild builds a Go program and returns a build result that includes the build context.
+// If the build succeeds, the caller must call the result's cleanup method once done with it.
 //
 // An error is returned if a non-user-correctable error has occurred.
-func sandboxBuild(ctx context.Context, tmpDir string, in []byte, vet bool) (br *buildResult, err error) {
+func sandboxBuild(ctx context.Context, tmpDir string, in []byte, opt *buildOptions) (br *buildResult, err error) {
 	start := time.Now()
 	defer func() {
 		status := "success"
@@ -426,21 +846,49 @@ func sandboxBuild(ctx context.Context, tmpDir string, in []byte, vet bool) (br *
 	}
 
 	br = new(buildResult)
-	defer br.cleanup()
+	defer func(b *buildResult) {
+		// A successful build's goPath is kept for vet; the
+		// caller cleans it up.
+		if err != nil || br != b || b.errorMessage != "" {
+			b.cleanup()
+		}
+	}(br)
 	var buildPkgArg = "."
 	if files.Num() == 1 && len(files.Data(progName)) > 0 {
 		buildPkgArg = progName
 		src := files.Data(progName)
-		if code := getTestProg(src); code != nil {
+		if code := getTestProg(src, opt); code != nil {
 			br.testParam = "-test.v"
 			files.AddFile(progName, code)
+			if opt.coverage {
+				br.coverLines = bytes.Count(src, []byte("\n")) + 1
+				br.outputFiles = append(br.outputFiles, coverFile)
+			}
 		}
 	}
 
-	if !files.Contains("go.mod") {
+	// A go.work file makes the snippet a Go workspace of the modules
+	// in it, whose main module is built instead of the root directory.
+	if files.Contains("go.work") {
+		buildPkgArg, err = workspaceMain(files)
+		if err != nil {
+			return &buildResult{errorMessage: err.Error()}, nil
+		}
+	} else if !files.Contains("go.mod") {
 		files.AddFile("go.mod", []byte("module play\n"))
 	}
 
+	if opt.profile != "" || opt.trace {
+		addProfiling(files, buildPkgArg, opt, br.testParam != "")
+		br.profile, br.trace = opt.profile, opt.trace
+		if opt.profile != "" {
+			br.outputFiles = append(br.outputFiles, profileFile)
+		}
+		if opt.trace {
+			br.outputFiles = append(br.outputFiles, traceFile)
+		}
+	}
+
 	for f, src := range files.m {
 		// Before multi-file support we required that the
 		// program be in package main, so continue to do that
@@ -465,35 +913,102 @@ func sandboxBuild(ctx context.Context, tmpDir string, in []byte, vet bool) (br *
 		}
 	}
 
+	if modPolicy != nil {
+		if errs := modPolicy.checkFiles(files); len(errs) > 0 {
+			br.errorMessage = policyErrorMessage(errs)
+			br.compileErrors = errs
+			return br, nil
+		}
+	}
+
+	// Wait for a build worker, so that bursts of requests queue up
+	// instead of all building at once. The worker is held for all the
+	// go commands below: the module listing, go tool cover and go
+	// build, with the shared caches.
+	release, err := acquireBuild(ctx, opt.queued)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	br.exePath = filepath.Join(tmpDir, "a.out")
 	goCache := filepath.Join(tmpDir, "gocache")
 
 	cmd := exec.Command("/usr/local/go-faketime/bin/go", "build", "-o", br.exePath, "-tags=faketime")
 	cmd.Dir = tmpDir
 	cmd.Env = []string{"GOOS=linux", "GOARCH=amd64", "GOROOT=/usr/local/go-faketime"}
+	if caches != nil {
+		goCache = caches.goCache
+		cmd.Env = append(cmd.Env, "GOMODCACHE="+caches.modCache)
+	}
 	cmd.Env = append(cmd.Env, "GOCACHE="+goCache)
 	cmd.Env = append(cmd.Env, "CGO_ENABLED=0")
 	cmd.Env = append(cmd.Env, "PATH="+os.Getenv("PATH"))
-	if os.Getenv("GOPRIVATE") != "" || os.Getenv("GONOPROXY") != "" || os.Getenv("GONOSUMDB") != "" {
+	if nosumdb := playgroundGonosumdb(); os.Getenv("GOPRIVATE") != "" || os.Getenv("GONOPROXY") != "" || nosumdb != "" {
 		cmd.Env = append(cmd.Env, "GOPRIVATE="+os.Getenv("GOPRIVATE"))
 		cmd.Env = append(cmd.Env, "GONOPROXY="+os.Getenv("GONOPROXY"))
-		cmd.Env = append(cmd.Env, "GONOSUMDB="+os.Getenv("GONOSUMDB"))
+		cmd.Env = append(cmd.Env, "GONOSUMDB="+nosumdb)
 	}
 	// Create a GOPATH just for modules to be downloaded
 	// into GOPATH/pkg/mod.
 	cmd.Args = append(cmd.Args, "-modcacherw")
-	cmd.Args = append(cmd.Args, "-mod=mod")
+	if mod := modFlag(tmpDir); mod != "" {
+		cmd.Args = append(cmd.Args, mod)
+	}
 	br.goPath, err = ioutil.TempDir("", "gopath-")
 	if err != nil {
 		log.Printf("error creating temp directory: %v", err)
 		return nil, fmt.Errorf("error creating temp directory: %v", err)
 	}
 	cmd.Env = append(cmd.Env, "GO111MODULE=on", "GOPROXY="+playgroundGoproxy())
+	if sumdb := playgroundGosumdb(); sumdb != "" {
+		cmd.Env = append(cmd.Env, "GOSUMDB="+sumdb)
+	}
+	if opt.gcflags != "" {
+		cmd.Args = append(cmd.Args, "-gcflags="+userPkgPattern(files, buildPkgArg)+opt.gcflags)
+	}
+	if opt.toolexec != "" {
+		cmd.Args = append(cmd.Args, "-toolexec="+opt.toolexec)
+	}
 	cmd.Args = append(cmd.Args, buildPkgArg)
 	cmd.Env = append(cmd.Env, "GOPATH="+br.goPath)
+	cmd.Env = append(cmd.Env, opt.env...)
 	out := &bytes.Buffer{}
 	cmd.Stderr, cmd.Stdout = out, out
 
+	if modPolicy != nil {
+		// Check the whole module graph before building, so that
+		// nothing denied is built and run, walking it first so that
+		// nothing denied is even downloaded.
+		listCtx, cancel := context.WithTimeout(ctx, maxBuildTime)
+		var errs []compileError
+		if modFlag(tmpDir) != "-mod=vendor" {
+			errs = modPolicy.checkRequirements(listCtx, cmd.Path, tmpDir, cmd.Env)
+		}
+		if len(errs) == 0 {
+			list := exec.Command(cmd.Path, listModulesArgs(modFlag(tmpDir), buildPkgArg)...)
+			list.Dir, list.Env = cmd.Dir, cmd.Env
+			errs = modPolicy.checkGraph(listCtx, list)
+		}
+		cancel()
+		if len(errs) > 0 {
+			br.errorMessage = policyErrorMessage(errs)
+			br.compileErrors = errs
+			return br, nil
+		}
+	}
+
+	if br.coverLines > 0 {
+		if msg, err := instrumentCoverage(ctx, cmd); err != nil {
+			return nil, err
+		} else if msg != "" {
+			br.errorMessage = msg
+			return br, nil
+		}
+		// Leave the uninstrumented program for vet.
+		defer ioutil.WriteFile(filepath.Join(tmpDir, progName), files.Data(progName), 0644)
+	}
+
 	log.Printf("Command ==> %v", cmd.String())
 	log.Printf("Env     ==> %v", cmd.Env)
 
@@ -503,9 +1018,12 @@ func sandboxBuild(ctx context.Context, tmpDir string, in []byte, vet bool) (br *
 	}
 	ctx, cancel := context.WithTimeout(ctx, maxBuildTime)
 	defer cancel()
-	if err := internal.WaitOrStop(ctx, cmd, os.Interrupt, 250*time.Millisecond); err != nil {
+	err = internal.WaitOrStop(ctx, cmd, os.Interrupt, 250*time.Millisecond)
+	br.output = out.Bytes()
+	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
 			br.errorMessage = fmt.Sprintln(goBuildTimeoutError)
+			br.compileErrors = []compileError{{Message: goBuildTimeoutError}}
 		} else if ee := (*exec.ExitError)(nil); !errors.As(err, &ee) {
 			log.Printf("error building go source: %v", err)
 			return nil, fmt.Errorf("error building go source: %v", err)
@@ -517,6 +1035,7 @@ func sandboxBuild(ctx context.Context, tmpDir string, in []byte, vet bool) (br *
 		// "go build", invoked with a file name, puts this odd
 		// message before any compile errors; strip it.
 		br.errorMessage = strings.Replace(br.errorMessage, "# command-line-arguments\n", "", 1)
+		br.compileErrors = append(br.compileErrors, parseCompileErrors(out.Bytes(), tmpDir)...)
 
 		return br, nil
 	}
@@ -529,19 +1048,141 @@ func sandboxBuild(ctx context.Context, tmpDir string, in []byte, vet bool) (br *
 		log.Printf("invalid binary size %d", fi.Size())
 		return nil, fmt.Errorf("invalid binary size %d", fi.Size())
 	}
-	if vet {
-		// TODO: do this concurrently with the execution to reduce latency.
-		br.vetOut, err = vetCheckInDir(ctx, tmpDir, br.goPath)
+	if caches != nil {
+		caches.markUsed(goSums(tmpDir, files))
+	}
+	br.pkg = buildPkgArg
+	return br, nil
+}
+
+// startVet starts go vet on the program built in dir and returns a
+// function that waits for its diagnostics and output, as returned by
+// vetCheckInDir. The function may be called more than once. The caller
+// must not clean up b or dir until vet is done.
+func (b *buildResult) startVet(ctx context.Context, dir string) (wait func() ([]diagnostic, string, error)) {
+	var (
+		diags []diagnostic
+		out   string
+	)
+	done := inBackground(ctx, func(ctx context.Context) (err error) {
+		diags, out, err = vetCheckInDir(ctx, dir, b.pkg, b.goPath)
 		if err != nil {
 			log.Printf("running vet: %v", err)
-			return nil, fmt.Errorf("running vet: %v", err)
+			return fmt.Errorf("running vet: %w", err)
 		}
+		diags = b.userFixes(diags)
+		return nil
+	})
+	return func() ([]diagnostic, string, error) {
+		err := done()
+		return diags, out, err
 	}
-	return br, nil
 }
 
-// sandboxRun runs a Go binary in a sandbox environment.
-func sandboxRun(ctx context.Context, exePath string, testParam string) (execRes sandboxtypes.Response, err error) {
+// startAnalysis is like startVet for the named analyzers, run by
+// analyzeInDir.
+func (b *buildResult) startAnalysis(ctx context.Context, dir string, names []string) (wait func() ([]diagnostic, error)) {
+	var diags []diagnostic
+	done := inBackground(ctx, func(ctx context.Context) (err error) {
+		diags, err = analyzeInDir(ctx, dir, b.pkg, b.goPath, names)
+		if err != nil {
+			log.Printf("running analyzers: %v", err)
+			return fmt.Errorf("running analyzers: %w", err)
+		}
+		diags = b.userFixes(diags)
+		return nil
+	})
+	return func() ([]diagnostic, error) {
+		err := done()
+		return diags, err
+	}
+}
+
+// userFixes drops the suggested fixes of diags if the program was
+// rewritten into a test program, or for profiling or tracing, before it
+// was built, since their offsets may then not match the user's source.
+func (b *buildResult) userFixes(diags []diagnostic) []diagnostic {
+	if b.testParam == "" && b.profile == "" && !b.trace {
+		return diags
+	}
+	for i := range diags {
+		diags[i].SuggestedFixes = nil
+	}
+	return diags
+}
+
+// inBackground calls f in a new goroutine, within maxBuildTime, and
+// returns a function that waits for f to return. The function may be
+// called more than once.
+func inBackground(ctx context.Context, f func(context.Context) error) (wait func() error) {
+	var (
+		done = make(chan struct{})
+		err  error
+	)
+	go func() {
+		defer close(done)
+		ctx, cancel := context.WithTimeout(ctx, maxBuildTime)
+		defer cancel()
+		err = f(ctx)
+	}()
+	return func() error {
+		<-done
+		return err
+	}
+}
+
+// sandboxRun runs the Go binary built by br in a sandbox environment.
+func sandboxRun(ctx context.Context, br *buildResult) (execRes sandboxtypes.Response, err error) {
+	err = doSandboxRun(ctx, sandboxBackendURL(), br, &execRes, func(body io.Reader) error {
+		if err := json.NewDecoder(body).Decode(&execRes); err != nil {
+			log.Printf("JSON decode error from backend: %v", err)
+			return errors.New("error parsing JSON from backend")
+		}
+		return nil
+	})
+	return execRes, err
+}
+
+// sandboxRunStream is like sandboxRun, but uses the backend's streaming
+// endpoint and calls output with each chunk of the program's output
+// as the backend forwards it. The returned Response holds all of the
+// output, as with sandboxRun.
+func sandboxRunStream(ctx context.Context, br *buildResult, output func(kind string, data []byte)) (execRes sandboxtypes.Response, err error) {
+	err = doSandboxRun(ctx, sandboxBackendURL()+"/stream", br, &execRes, func(body io.Reader) error {
+		dec := json.NewDecoder(body)
+		for {
+			var f sandboxtypes.StreamFrame
+			if err := dec.Decode(&f); err != nil {
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					execRes.Error = runTimeoutError
+					return nil
+				}
+				log.Printf("JSON decode error from backend: %v", err)
+				return errors.New("error parsing JSON from backend")
+			}
+			switch f.Kind {
+			case "stdout":
+				execRes.Stdout = append(execRes.Stdout, f.Data...)
+				output(f.Kind, f.Data)
+			case "stderr":
+				execRes.Stderr = append(execRes.Stderr, f.Data...)
+				output(f.Kind, f.Data)
+			case "exit":
+				execRes.Error = f.Error
+				execRes.ExitCode = f.ExitCode
+				execRes.Files = f.Files
+				execRes.Usage = f.Usage
+				return nil
+			}
+		}
+	})
+	return execRes, err
+}
+
+// doSandboxRun sends the binary built by br to the sandbox backend at
+// url and reads the backend's response body with read. If the run times
+// out before the backend responds, execRes.Error is set instead.
+func doSandboxRun(ctx context.Context, url string, br *buildResult, execRes *sandboxtypes.Response, read func(body io.Reader) error) (err error) {
 	start := time.Now()
 	defer func() {
 		status := "success"
@@ -553,53 +1194,101 @@ func sandboxRun(ctx context.Context, exePath string, testParam string) (execRes
 		stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(kGoBuildSuccess, status)},
 			mGoRunLatency.M(float64(time.Since(start))/float64(time.Millisecond)))
 	}()
-	exeBytes, err := ioutil.ReadFile(exePath)
+	exeBytes, err := ioutil.ReadFile(br.exePath)
 	if err != nil {
-		return execRes, err
+		return err
 	}
 	ctx, cancel := context.WithTimeout(ctx, maxRunTime)
 	defer cancel()
-	sreq, err := http.NewRequestWithContext(ctx, "POST", sandboxBackendURL(), bytes.NewReader(exeBytes))
+	sreq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(exeBytes))
 	if err != nil {
-		return execRes, fmt.Errorf("NewRequestWithContext %q: %w", sandboxBackendURL(), err)
+		return fmt.Errorf("NewRequestWithContext %q: %w", url, err)
 	}
 	sreq.Header.Add("Idempotency-Key", "1") // lets Transport do retries with a POST
-	if testParam != "" {
-		sreq.Header.Add("X-Argument", testParam)
+	if br.testParam != "" {
+		sreq.Header.Add("X-Argument", br.testParam)
+	}
+	for _, name := range br.outputFiles {
+		sreq.Header.Add("X-Output-File", name)
 	}
 	sreq.GetBody = func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(exeBytes)), nil }
 	res, err := sandboxBackendClient().Do(sreq)
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			execRes.Error = runTimeoutError
-			return execRes, nil
+			return nil
 		}
-		return execRes, fmt.Errorf("POST %q: %w", sandboxBackendURL(), err)
+		return fmt.Errorf("POST %q: %w", url, err)
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
 		log.Printf("unexpected response from backend: %v", res.Status)
-		return execRes, fmt.Errorf("unexpected response from backend: %v", res.Status)
+		return fmt.Errorf("unexpected response from backend: %v", res.Status)
 	}
-	if err := json.NewDecoder(res.Body).Decode(&execRes); err != nil {
-		log.Printf("JSON decode error from backend: %v", err)
-		return execRes, errors.New("error parsing JSON from backend")
-	}
-	return execRes, nil
+	return read(res.Body)
 }
 
 // playgroundGoproxy returns the GOPROXY environment config the playground should use.
 // It is fetched from the environment variable PLAY_GOPROXY. A missing or empty
-// value for PLAY_GOPROXY returns the default value of https://proxy.golang.org.
+// value for PLAY_GOPROXY returns the module proxy served by this server, if any,
+// followed by PLAY_GOPROXY_FALLBACK if set, or else the default value of
+// https://goproxy.cn.
 func playgroundGoproxy() string {
 	proxypath := os.Getenv("PLAY_GOPROXY")
 	if proxypath != "" {
 		return proxypath
 	}
+	if localGoproxy != "" {
+		if fallback := os.Getenv("PLAY_GOPROXY_FALLBACK"); fallback != "" {
+			return localGoproxy + "," + fallback
+		}
+		return localGoproxy
+	}
 	// return "https://proxy.golang.org"
 	return "https://goproxy.cn"
 }
 
+// usesLocalGoproxy reports whether builds use the module proxy served
+// by this server.
+func usesLocalGoproxy() bool {
+	return localGoproxy != "" && os.Getenv("PLAY_GOPROXY") == ""
+}
+
+// playgroundGosumdb returns the GOSUMDB environment config the playground
+// should use, or "" for the go command's default. It is fetched from the
+// environment variable PLAY_GOSUMDB. Otherwise, the checksum database is
+// turned off when builds use only the module proxy served by this server,
+// which may run without access to the database. With a fallback proxy,
+// the modules it serves are left out of checks by playgroundGonosumdb
+// instead.
+func playgroundGosumdb() string {
+	if sumdb := os.Getenv("PLAY_GOSUMDB"); sumdb != "" {
+		return sumdb
+	}
+	if usesLocalGoproxy() && os.Getenv("PLAY_GOPROXY_FALLBACK") == "" {
+		return "off"
+	}
+	return ""
+}
+
+// playgroundGonosumdb returns the GONOSUMDB environment config the
+// playground should use: GONOSUMDB, which defaults to GOPRIVATE, and
+// the paths of the modules served by this server if builds use it,
+// since they may be modules the checksum database does not know about.
+func playgroundGonosumdb() string {
+	nosumdb := os.Getenv("GONOSUMDB")
+	if nosumdb == "" {
+		nosumdb = os.Getenv("GOPRIVATE")
+	}
+	if !usesLocalGoproxy() || len(localModules) == 0 {
+		return nosumdb
+	}
+	if nosumdb == "" {
+		return strings.Join(localModules, ",")
+	}
+	return nosumdb + "," + strings.Join(localModules, ",")
+}
+
 // healthCheck attempts to build a binary from the source in healthProg.
 // It returns any error returned from sandboxBuild, or nil if none is returned.
 func (s *server) healthCheck(ctx context.Context) error {
@@ -608,14 +1297,14 @@ func (s *server) healthCheck(ctx context.Context) error {
 		return fmt.Errorf("error creating temp directory: %v", err)
 	}
 	defer os.RemoveAll(tmpDir)
-	br, err := sandboxBuild(ctx, tmpDir, []byte(healthProg), false)
+	br, err := sandboxBuild(ctx, tmpDir, []byte(healthProg), &buildOptions{})
 	if err != nil {
 		return err
 	}
 	if br.errorMessage != "" {
 		return errors.New(br.errorMessage)
 	}
-	return nil
+	return br.cleanup()
 }
 
 // sandboxBackendURL returns the URL of the sandbox backend that
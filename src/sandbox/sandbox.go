@@ -26,7 +26,8 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
-	"runtime"
+	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -36,23 +37,36 @@ import (
 	"go.opencensus.io/tag"
 	"go.opencensus.io/trace"
 	"golang.org/x/playground/internal"
+	"golang.org/x/playground/internal/config"
 	"golang.org/x/playground/sandbox/sandboxtypes"
 )
 
+// defaults is the default configuration, shown in the flags' help.
+// The flags override the configuration; see config.Load.
+var defaults = config.Default().Sandbox
+
 var (
-	listenAddr = flag.String("listen", ":80", "HTTP server listen address. Only applicable when --mode=server")
+	configFile = flag.String("config", os.Getenv("PLAY_CONFIG"), "YAML configuration file shared with the web server; see playground.example.yaml. Environment variables and flags override it.")
+	listenAddr = flag.String("listen", defaults.Listen, "HTTP server listen address. Only applicable when --mode=server")
 	mode       = flag.String("mode", "server", "Whether to run in \"server\" mode or \"contained\" mode. The contained mode is used internally by the server mode.")
-	dev        = flag.Bool("dev", false, "run in dev mode (show help messages)")
-	numWorkers = flag.Int("workers", runtime.NumCPU(), "number of parallel gvisor containers to pre-spin up & let run concurrently")
-	container  = flag.String("untrusted-container", "gcr.io/golang-org/playground-sandbox-gvisor:latest", "container image name that hosts the untrusted binary under gvisor")
+	dev        = flag.Bool("dev", defaults.Dev, "run in dev mode (show help messages)")
+	numWorkers = flag.Int("workers", defaults.Workers, "number of parallel gvisor containers to pre-spin up & let run concurrently")
+	container  = flag.String("untrusted-container", defaults.UntrustedContainer, "container image name that hosts the untrusted binary under gvisor")
+	// The value of run-timeout is read by config.Load, like that of
+	// the other flags of the configuration. The server mode passes it
+	// on to the contained mode, which has no configuration file but
+	// bounds the program's run with it too.
+	_ = flag.Duration("run-timeout", defaults.RunTimeout, "maximum time a program runs")
 )
 
-const (
-	maxBinarySize    = 100 << 20
-	startTimeout     = 30 * time.Second
-	runTimeout       = 5 * time.Second
-	maxOutputSize    = 100 << 20
-	memoryLimitBytes = 100 << 20
+const startTimeout = 30 * time.Second
+
+// Limits on programs, set in main from the configuration.
+var (
+	maxBinarySize    int64 = 100 << 20
+	runTimeout             = 5 * time.Second
+	maxOutputSize    int64 = 100 << 20
+	memoryLimitBytes int64 = 100 << 20
 )
 
 var (
@@ -70,6 +84,15 @@ const containedStartMessage = "golang-gvisor-process-started\n"
 // but before it's run.
 var containedStderrHeader = []byte("golang-gvisor-process-got-input\n")
 
+// containedResultHeader is written to stderr by the gvisor-contained
+// process after the binary exits, followed by the JSON processResult of
+// the run.
+var containedResultHeader = []byte("golang-gvisor-process-result\n")
+
+// outputDir is the directory of the gvisor container in which the
+// binary writes its output files.
+const outputDir = "/tmpfs/out"
+
 var (
 	readyContainer chan *Container
 	runSem         chan struct{}
@@ -107,6 +130,11 @@ var httpServer *http.Server
 
 func main() {
 	flag.Parse()
+	cfg, err := config.Load(*configFile, flag.CommandLine)
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	configure(&cfg.Sandbox)
 	if *mode == "contained" {
 		runInGvisor()
 		panic("runInGvisor didn't exit")
@@ -142,6 +170,7 @@ func main() {
 	mux.Handle("/healthz", ochttp.WithRouteTag(http.HandlerFunc(healthHandler), "/healthz"))
 	mux.Handle("/", ochttp.WithRouteTag(http.HandlerFunc(rootHandler), "/"))
 	mux.Handle("/run", ochttp.WithRouteTag(http.HandlerFunc(runHandler), "/run"))
+	mux.Handle("/run/stream", ochttp.WithRouteTag(http.HandlerFunc(runStreamHandler), "/run/stream"))
 
 	makeWorkers()
 	go internal.PeriodicallyDo(context.Background(), 10*time.Second, func(ctx context.Context, _ time.Time) {
@@ -156,6 +185,13 @@ func main() {
 	log.Fatal(httpServer.ListenAndServe())
 }
 
+// configure applies the settings of c.
+func configure(c *config.Sandbox) {
+	*listenAddr, *dev, *numWorkers, *container = c.Listen, c.Dev, c.Workers, c.UntrustedContainer
+	runTimeout, memoryLimitBytes = c.RunTimeout, c.MemoryLimitBytes
+	maxOutputSize, maxBinarySize = c.MaxOutputSize, c.MaxBinarySize
+}
+
 // dockerContainer is the structure of each line output from docker ps.
 type dockerContainer struct {
 	// ID is the docker container ID.
@@ -300,10 +336,26 @@ func rootHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // processMeta is the JSON sent to the gvisor container before the untrusted binary.
-// It currently contains only the arguments to pass to the binary.
+// It contains the arguments to pass to the binary and the names of the
+// output files to send back once it exits.
 // It might contain environment or other things later.
 type processMeta struct {
-	Args []string `json:"args"`
+	Args  []string `json:"args"`
+	Files []string `json:"files,omitempty"`
+}
+
+// processResult is the JSON written to stderr by the gvisor container
+// after the binary exits: the output files it wrote and the resources
+// it used, except for its output, which the HTTP server measures.
+type processResult struct {
+	Files map[string][]byte  `json:"files,omitempty"`
+	Usage sandboxtypes.Usage `json:"usage"`
+}
+
+// validOutputFile reports whether name, from an X-Output-File header,
+// is the base name of a file.
+func validOutputFile(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\\x00")
 }
 
 // runInGvisor is run when we're now inside gvisor. We have no network
@@ -340,8 +392,15 @@ func runInGvisor() {
 
 	cmd := exec.Command(binPath)
 	cmd.Args = append(cmd.Args, meta.Args...)
+	if len(meta.Files) > 0 {
+		if err := os.Mkdir(outputDir, 0755); err != nil {
+			log.Fatalf("creating output directory: %v", err)
+		}
+		cmd.Env = append(os.Environ(), sandboxtypes.OutputDirEnv+"="+outputDir)
+	}
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	start := time.Now()
 	if err := cmd.Start(); err != nil {
 		log.Fatalf("cmd.Start(): %v", err)
 	}
@@ -352,10 +411,52 @@ func runInGvisor() {
 			fmt.Fprintln(os.Stderr, "timeout running program")
 		}
 	}
+	res := processResult{Usage: processUsage(cmd.ProcessState, time.Since(start))}
+	if len(meta.Files) > 0 {
+		res.Files = readOutputFiles(meta.Files)
+	}
+	writeProcessResult(&res)
 	os.Exit(errExitCode(err))
 	return
 }
 
+// writeProcessResult writes res to stderr, after containedResultHeader.
+func writeProcessResult(res *processResult) {
+	data, err := json.Marshal(res)
+	if err != nil {
+		log.Fatalf("encoding process result: %v", err)
+	}
+	if _, err := os.Stderr.Write(append(containedResultHeader, data...)); err != nil {
+		log.Fatalf("writing process result to stderr: %v", err)
+	}
+}
+
+// processUsage returns the resources used by the exited process ps,
+// which ran for wall.
+func processUsage(ps *os.ProcessState, wall time.Duration) sandboxtypes.Usage {
+	u := sandboxtypes.Usage{WallTime: wall}
+	if ps == nil {
+		return u
+	}
+	u.CPUTime = ps.UserTime() + ps.SystemTime()
+	if ru, ok := ps.SysUsage().(*syscall.Rusage); ok {
+		u.MaxRSS = int64(ru.Maxrss) * 1024 // in KiB on Linux
+	}
+	return u
+}
+
+// readOutputFiles returns the contents of the named files of outputDir
+// that the binary wrote, by name.
+func readOutputFiles(names []string) map[string][]byte {
+	files := make(map[string][]byte)
+	for _, name := range names {
+		if data, err := ioutil.ReadFile(filepath.Join(outputDir, name)); err == nil {
+			files[name] = data
+		}
+	}
+	return files
+}
+
 func makeWorkers() {
 	ctx := context.Background()
 	stats.Record(ctx, mMaxContainers.M(int64(*numWorkers)))
@@ -447,7 +548,8 @@ func startContainer(ctx context.Context) (c *Container, err error) {
 			"--memory="+fmt.Sprint(memoryLimitBytes),
 
 			*container,
-			"--mode=contained")
+			"--mode=contained",
+			"--run-timeout="+runTimeout.String())
 	} else {
 		cmd = exec.Command("docker", "run",
 			"--name="+name,
@@ -459,7 +561,8 @@ func startContainer(ctx context.Context) (c *Container, err error) {
 			"--memory="+fmt.Sprint(memoryLimitBytes),
 
 			*container,
-			"--mode=contained")
+			"--mode=contained",
+			"--run-timeout="+runTimeout.String())
 	}
 
 	stdin, err := cmd.StdinPipe()
@@ -528,6 +631,26 @@ func startContainer(ctx context.Context) (c *Container, err error) {
 }
 
 func runHandler(w http.ResponseWriter, r *http.Request) {
+	serveRun(w, r, nil)
+}
+
+// runStreamHandler is like runHandler, but streams the output of the
+// program as it is produced. The response is a sequence of
+// newline-separated JSON sandboxtypes.StreamFrames: "stdout" and
+// "stderr" frames as the program writes, followed by one "exit" frame.
+func runStreamHandler(w http.ResponseWriter, r *http.Request) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	serveRun(w, r, &streamWriter{w: w, f: f})
+}
+
+// serveRun runs the binary in the body of the POST request r in a
+// sandbox container. If sw is nil, the result is sent as a single JSON
+// sandboxtypes.Response; otherwise the output is streamed through sw.
+func serveRun(w http.ResponseWriter, r *http.Request, sw *streamWriter) {
 	t0 := time.Now()
 	tlast := t0
 	var logmu sync.Mutex
@@ -543,12 +666,35 @@ func runHandler(w http.ResponseWriter, r *http.Request) {
 		tlast = t
 		log.Print(fmt.Sprintf("+%10v +%10v ", d0, d) + fmt.Sprintf(format, args...))
 	}
-	logf("/run")
+	logf("%s", r.URL.Path)
+
+	// respond and fail send the outcome of the run in the form the
+	// client asked for. Once a stream has started, failures can only
+	// be reported in its final frame.
+	respond := func(res *sandboxtypes.Response) { sendResponse(w, res) }
+	fail := func(msg string, code int) { http.Error(w, msg, code) }
+	if sw != nil {
+		respond = sw.exit
+		fail = func(msg string, code int) {
+			if !sw.started() {
+				http.Error(w, msg, code)
+				return
+			}
+			sw.exit(&sandboxtypes.Response{Error: msg})
+		}
+	}
 
 	if r.Method != "POST" {
 		http.Error(w, "expected a POST", http.StatusBadRequest)
 		return
 	}
+	outputFiles := r.Header["X-Output-File"]
+	for _, name := range outputFiles {
+		if !validOutputFile(name) {
+			http.Error(w, fmt.Sprintf("invalid X-Output-File %q", name), http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Bound the number of requests being processed at once.
 	// (Before we slurp the binary into memory)
@@ -579,7 +725,20 @@ func runHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	logf("got container %s", c.name)
 
-	ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+	var cut *cutWriter
+	if sw != nil {
+		c.stdout.setTee(sw.writer("stdout"))
+		// Drop the spam gvisor writes to stderr before the program
+		// starts, and the process result that follows its stderr.
+		cut = &cutWriter{dst: sw.writer("stderr"), cutAt: containedResultHeader}
+		c.stderr.setTee(&switchWriter{switchAfter: containedStderrHeader, dst1: ioutil.Discard, dst2: cut})
+		sw.start()
+	}
+
+	// Derive the run's context from the request's, so that the container
+	// is killed and its slot freed as soon as the client goes away,
+	// whether because the user killed the program or gave up waiting.
+	ctx, cancel := context.WithTimeout(r.Context(), runTimeout)
 	closed := make(chan struct{})
 	defer func() {
 		logf("leaving handler; about to close container")
@@ -588,34 +747,45 @@ func runHandler(w http.ResponseWriter, r *http.Request) {
 	}()
 	go func() {
 		<-ctx.Done()
-		if ctx.Err() == context.DeadlineExceeded {
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
 			logf("timeout")
+		case r.Context().Err() != nil:
+			logf("client side cancellation")
 		}
 		c.Close()
 		close(closed)
 	}()
 	var meta processMeta
 	meta.Args = r.Header["X-Argument"]
+	meta.Files = outputFiles
 	metaJSON, _ := json.Marshal(&meta)
 	metaJSON = append(metaJSON, '\n')
 	if _, err := c.stdin.Write(metaJSON); err != nil {
 		log.Printf("failed to write meta to child: %v", err)
-		http.Error(w, "unknown error during docker run", http.StatusInternalServerError)
+		fail("unknown error during docker run", http.StatusInternalServerError)
 		return
 	}
 	if _, err := c.stdin.Write(bin); err != nil {
 		log.Printf("failed to write binary to child: %v", err)
-		http.Error(w, "unknown error during docker run", http.StatusInternalServerError)
+		fail("unknown error during docker run", http.StatusInternalServerError)
 		return
 	}
 	c.stdin.Close()
 	logf("wrote+closed")
 	err = c.Wait()
+	if cut != nil {
+		cut.flush()
+	}
 	select {
 	case <-ctx.Done():
-		// Timed out or canceled before or exactly as Wait returned.
-		// Either way, treat it as a timeout.
-		sendError(w, "timeout running program")
+		if cerr := r.Context().Err(); cerr != nil {
+			// Nobody is listening for the response.
+			log.Printf("run, client side cancellation: %v", cerr)
+			return
+		}
+		// Timed out before or exactly as Wait returned.
+		respond(&sandboxtypes.Response{Error: "timeout running program"})
 		return
 	default:
 		logf("finished running; about to close container")
@@ -625,25 +795,35 @@ func runHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if c.stderr.n < 0 || c.stdout.n < 0 {
 			// Do not send truncated output, just send the error.
-			sendError(w, errTooMuchOutput.Error())
+			respond(&sandboxtypes.Response{Error: errTooMuchOutput.Error()})
 			return
 		}
 		var ee *exec.ExitError
 		if !errors.As(err, &ee) {
-			http.Error(w, "unknown error during docker run", http.StatusInternalServerError)
+			fail("unknown error during docker run", http.StatusInternalServerError)
 			return
 		}
 		res.ExitCode = ee.ExitCode()
 	}
 	res.Stdout = c.stdout.dst.Bytes()
 	res.Stderr = cleanStderr(c.stderr.dst.Bytes())
-	sendResponse(w, res)
+	var pres *processResult
+	res.Stderr, pres = cutProcessResult(res.Stderr)
+	if pres != nil {
+		res.Files = pres.Files
+		res.Usage = &pres.Usage
+		res.Usage.OutputBytes = int64(len(res.Stdout) + len(res.Stderr))
+	}
+	respond(res)
 }
 
 // limitedWriter is an io.Writer that returns an errTooMuchOutput when the cap (n) is hit.
 type limitedWriter struct {
 	dst *bytes.Buffer
 	n   int64 // max bytes remaining
+
+	teeMu sync.Mutex
+	tee   io.Writer // if non-nil, also receives what is written to dst
 }
 
 // Write is an io.Writer function that returns errTooMuchOutput when the cap (n) is hit.
@@ -658,13 +838,110 @@ func (l *limitedWriter) Write(p []byte) (int, error) {
 
 	if int64(len(p)) > l.n {
 		n, err := l.dst.Write(p[:l.n])
+		l.copyToTee(p[:n])
 		if err != nil {
 			return n, err
 		}
 		return n, errTooMuchOutput
 	}
 
-	return l.dst.Write(p)
+	n, err := l.dst.Write(p)
+	l.copyToTee(p[:n])
+	return n, err
+}
+
+// setTee makes l copy everything written to it from now on to w.
+// It is safe to call while l is being written to.
+func (l *limitedWriter) setTee(w io.Writer) {
+	l.teeMu.Lock()
+	defer l.teeMu.Unlock()
+	l.tee = w
+}
+
+// copyToTee writes p to the tee, if any. Errors are ignored: a client
+// that went away must not stop the program's output from being recorded.
+func (l *limitedWriter) copyToTee(p []byte) {
+	l.teeMu.Lock()
+	defer l.teeMu.Unlock()
+	if l.tee != nil && len(p) > 0 {
+		l.tee.Write(p)
+	}
+}
+
+// streamWriter writes sandboxtypes.StreamFrames to the response of a
+// /run/stream request, flushing after each frame.
+type streamWriter struct {
+	w http.ResponseWriter
+	f http.Flusher
+
+	mu      sync.Mutex
+	begun   bool // whether the response header was sent
+	done    bool // whether the exit frame was sent
+	encoder *json.Encoder
+}
+
+// start sends the response header. No error can be reported with an
+// HTTP status after start has been called.
+func (s *streamWriter) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startLocked()
+}
+
+func (s *streamWriter) startLocked() {
+	if s.begun {
+		return
+	}
+	s.begun = true
+	s.w.Header().Set("Content-Type", "application/x-ndjson")
+	s.w.WriteHeader(http.StatusOK)
+	s.encoder = json.NewEncoder(s.w)
+	s.f.Flush()
+}
+
+func (s *streamWriter) started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.begun
+}
+
+// frame sends f, unless the exit frame was already sent.
+func (s *streamWriter) frame(f *sandboxtypes.StreamFrame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return
+	}
+	s.startLocked()
+	if f.Kind == "exit" {
+		s.done = true
+	}
+	if err := s.encoder.Encode(f); err != nil {
+		return
+	}
+	s.f.Flush()
+}
+
+// exit sends the final frame with the outcome in res. The output in
+// res is ignored, as it was already streamed.
+func (s *streamWriter) exit(res *sandboxtypes.Response) {
+	s.frame(&sandboxtypes.StreamFrame{Kind: "exit", Error: res.Error, ExitCode: res.ExitCode, Files: res.Files, Usage: res.Usage})
+}
+
+// writer returns an io.Writer that sends each write as a frame of the
+// given kind.
+func (s *streamWriter) writer(kind string) io.Writer {
+	return streamKindWriter{s, kind}
+}
+
+type streamKindWriter struct {
+	s    *streamWriter
+	kind string
+}
+
+func (w streamKindWriter) Write(p []byte) (int, error) {
+	w.s.frame(&sandboxtypes.StreamFrame{Kind: w.kind, Data: append([]byte(nil), p...)})
+	return len(p), nil
 }
 
 // switchWriter writes to dst1 until switchAfter is written, the it writes to dst2.
@@ -702,6 +979,57 @@ func (s *switchWriter) Write(p []byte) (int, error) {
 	return n + n2, err
 }
 
+// cutWriter writes to dst what is written to it up to the last
+// occurrence of cutAt, dropping cutAt and everything after it, as
+// cutProcessResult does. The last occurrence is only known at the end,
+// so everything from an occurrence of cutAt on is held back until the
+// next one, and bytes that may start cutAt are held back until the next
+// write, or flush.
+type cutWriter struct {
+	dst   io.Writer
+	cutAt []byte
+	held  []byte
+	cut   bool // whether held starts with cutAt
+}
+
+func (c *cutWriter) Write(p []byte) (int, error) {
+	buf := append(c.held, p...)
+	from := 0
+	if c.cut {
+		from = 1 // look for a later occurrence than the held one
+	}
+	var out []byte
+	if i := bytes.LastIndex(buf[from:], c.cutAt); i >= 0 {
+		c.cut = true
+		out, c.held = buf[:from+i], append([]byte(nil), buf[from+i:]...)
+	} else if c.cut {
+		c.held = buf
+	} else {
+		// Hold back the longest end of buf that starts cutAt.
+		k := len(c.cutAt) - 1
+		if k > len(buf) {
+			k = len(buf)
+		}
+		for ; k > 0 && !bytes.HasPrefix(c.cutAt, buf[len(buf)-k:]); k-- {
+		}
+		out, c.held = buf[:len(buf)-k], append([]byte(nil), buf[len(buf)-k:]...)
+	}
+	if len(out) > 0 {
+		if _, err := c.dst.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes the bytes held back, unless they follow cutAt.
+func (c *cutWriter) flush() {
+	if !c.cut && len(c.held) > 0 {
+		c.dst.Write(c.held)
+	}
+	c.held = nil
+}
+
 func errExitCode(err error) int {
 	if err == nil {
 		return 0
@@ -729,6 +1057,24 @@ func sendResponse(w http.ResponseWriter, r *sandboxtypes.Response) {
 	w.Write(jres)
 }
 
+// cutProcessResult splits the stderr of a container, once cleaned, into
+// the program's output and the processResult written after
+// containedResultHeader. The result is nil if there is none, as when
+// the container failed to run the program. The last header is used, in
+// case the program wrote one too.
+func cutProcessResult(x []byte) (stderr []byte, res *processResult) {
+	i := bytes.LastIndex(x, containedResultHeader)
+	if i == -1 {
+		return x, nil
+	}
+	res = new(processResult)
+	if err := json.Unmarshal(x[i+len(containedResultHeader):], res); err != nil {
+		log.Printf("error decoding process result: %v", err)
+		return x[:i], nil
+	}
+	return x[:i], res
+}
+
 // cleanStderr removes spam stderr lines from the beginning of x
 // and returns a slice of x.
 func cleanStderr(x []byte) []byte {
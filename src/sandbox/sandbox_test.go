@@ -6,12 +6,18 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
+	"net/http/httptest"
+	"os"
+	"os/exec"
 	"strings"
 	"testing"
 	"testing/iotest"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/playground/sandbox/sandboxtypes"
 )
 
 func TestLimitedWriter(t *testing.T) {
@@ -78,6 +84,55 @@ func TestLimitedWriter(t *testing.T) {
 	}
 }
 
+func TestLimitedWriterTee(t *testing.T) {
+	lw := &limitedWriter{dst: &bytes.Buffer{}, n: 10}
+	lw.Write([]byte("before"))
+	tee := &bytes.Buffer{}
+	lw.setTee(tee)
+	if _, err := lw.Write([]byte("after and beyond")); err != errTooMuchOutput {
+		t.Errorf("lw.Write() = _, %v; want %v", err, errTooMuchOutput)
+	}
+	if got, want := lw.dst.String(), "beforeafte"; got != want {
+		t.Errorf("lw.dst = %q; want %q", got, want)
+	}
+	if got, want := tee.String(), "afte"; got != want {
+		t.Errorf("tee = %q; want %q", got, want)
+	}
+}
+
+func TestStreamWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sw := &streamWriter{w: rec, f: rec}
+	if sw.started() {
+		t.Fatalf("sw.started() = true before any frame was sent")
+	}
+	io.WriteString(sw.writer("stdout"), "hello")
+	io.WriteString(sw.writer("stderr"), "oops")
+	sw.exit(&sandboxtypes.Response{ExitCode: 2, Stdout: []byte("ignored")})
+	io.WriteString(sw.writer("stdout"), "after exit")
+
+	if got, want := rec.Header().Get("Content-Type"), "application/x-ndjson"; got != want {
+		t.Errorf("Content-Type = %q; want %q", got, want)
+	}
+	var got []sandboxtypes.StreamFrame
+	dec := json.NewDecoder(rec.Body)
+	for dec.More() {
+		var f sandboxtypes.StreamFrame
+		if err := dec.Decode(&f); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		got = append(got, f)
+	}
+	want := []sandboxtypes.StreamFrame{
+		{Kind: "stdout", Data: []byte("hello")},
+		{Kind: "stderr", Data: []byte("oops")},
+		{Kind: "exit", ExitCode: 2},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("frames mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestSwitchWriter(t *testing.T) {
 	cases := []struct {
 		desc      string
@@ -185,6 +240,94 @@ func TestSwitchWriterMultipleWrites(t *testing.T) {
 	}
 }
 
+func TestCutWriter(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{name: "no cut", writes: []string{"out", "put"}, want: "output"},
+		{name: "cut", writes: []string{"output<CUT>files"}, want: "output"},
+		{name: "cut across writes", writes: []string{"output<C", "UT", ">files", "more"}, want: "output"},
+		{name: "false start", writes: []string{"a<C", "at>"}, want: "a<Cat>"},
+		{name: "held until flush", writes: []string{"output<CU"}, want: "output<CU"},
+		{name: "cut at last", writes: []string{"a<CUT>b", "c<CUT>files"}, want: "a<CUT>bc"},
+		{name: "cut at last across writes", writes: []string{"a<CUT>b<C", "UT>", "files"}, want: "a<CUT>b"},
+		{name: "cut at last in one write", writes: []string{"a<CUT>b<CUT>files"}, want: "a<CUT>b"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := &bytes.Buffer{}
+			cw := &cutWriter{dst: dst, cutAt: []byte("<CUT>")}
+			for _, w := range tt.writes {
+				if n, err := cw.Write([]byte(w)); n != len(w) || err != nil {
+					t.Errorf("cw.Write(%q) = %d, %v; want %d, nil", w, n, err, len(w))
+				}
+			}
+			cw.flush()
+			if got := dst.String(); got != tt.want {
+				t.Errorf("written %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCutProcessResult(t *testing.T) {
+	in := append([]byte("panic: oops\n"), containedResultHeader...)
+	in = append(in, `{"files":{"cpu.pprof":"AQID"},"usage":{"wallTime":3000000,"cpuTime":2000000,"maxRSS":4096,"outputBytes":0}}`...)
+	stderr, res := cutProcessResult(in)
+	if string(stderr) != "panic: oops\n" {
+		t.Errorf("stderr = %q; want %q", stderr, "panic: oops\n")
+	}
+	want := &processResult{
+		Files: map[string][]byte{"cpu.pprof": {1, 2, 3}},
+		Usage: sandboxtypes.Usage{WallTime: 3 * time.Millisecond, CPUTime: 2 * time.Millisecond, MaxRSS: 4096},
+	}
+	if diff := cmp.Diff(want, res); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+
+	// A header written by the program is part of its output.
+	forged := append(append([]byte("x"), containedResultHeader...), in...)
+	if stderr, _ := cutProcessResult(forged); !bytes.HasSuffix(stderr, []byte("panic: oops\n")) {
+		t.Errorf("stderr of forged result = %q; want it to end with the program's output", stderr)
+	}
+
+	stderr, res = cutProcessResult([]byte("no result"))
+	if string(stderr) != "no result" || res != nil {
+		t.Errorf("cutProcessResult(%q) = %q, %v; want input, nil", "no result", stderr, res)
+	}
+}
+
+func TestProcessUsage(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("running test binary: %v", err)
+	}
+	u := processUsage(cmd.ProcessState, time.Second)
+	if u.WallTime != time.Second || u.CPUTime <= 0 || u.MaxRSS <= 0 {
+		t.Errorf("processUsage = %+v; want 1s of wall time and some CPU time and memory", u)
+	}
+	if u := processUsage(nil, time.Second); u != (sandboxtypes.Usage{WallTime: time.Second}) {
+		t.Errorf("processUsage(nil) = %+v; want only the wall time", u)
+	}
+}
+
+func TestValidOutputFile(t *testing.T) {
+	for name, want := range map[string]bool{
+		"cpu.pprof":   true,
+		"trace.out":   true,
+		"":            false,
+		"..":          false,
+		"../etc/x":    false,
+		"/etc/passwd": false,
+		`a\b`:         false,
+	} {
+		if got := validOutputFile(name); got != want {
+			t.Errorf("validOutputFile(%q) = %v; want %v", name, got, want)
+		}
+	}
+}
+
 func TestParseDockerContainers(t *testing.T) {
 	cases := []struct {
 		desc    string
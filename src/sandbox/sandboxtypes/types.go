@@ -6,6 +6,8 @@
 // to communicate between the different sandbox components.
 package sandboxtypes
 
+import "time"
+
 // Response is the response from the x/playground/sandbox backend to
 // the x/playground frontend.
 //
@@ -19,4 +21,47 @@ type Response struct {
 	ExitCode int    `json:"exitCode"`
 	Stdout   []byte `json:"stdout"`
 	Stderr   []byte `json:"stderr"`
+
+	// Files holds the contents of the files requested with
+	// X-Output-File headers that the program wrote, by name.
+	Files map[string][]byte `json:"files,omitempty"`
+
+	// Usage, if non-nil, is the resources the program used.
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// Usage is the resources used by a run of a program in the sandbox.
+type Usage struct {
+	// WallTime is the time from the start of the program to its exit.
+	WallTime time.Duration `json:"wallTime"`
+	// CPUTime is the user and system CPU time of the program.
+	CPUTime time.Duration `json:"cpuTime"`
+	// MaxRSS is the peak resident set size of the program, in bytes.
+	MaxRSS int64 `json:"maxRSS"`
+	// OutputBytes is the size of the program's standard output and
+	// error.
+	OutputBytes int64 `json:"outputBytes"`
+}
+
+// OutputDirEnv is the environment variable that holds the directory in
+// which a program writes the files requested with X-Output-File
+// headers. Only their base names are given in the headers.
+const OutputDirEnv = "PLAY_OUTPUT_DIR"
+
+// StreamFrame is one frame of the response from the sandbox backend's
+// /run/stream endpoint, which sends newline-separated JSON frames as the
+// program runs instead of a single Response when it exits.
+type StreamFrame struct {
+	// Kind is "stdout" or "stderr" for program output, or "exit"
+	// for the last frame of the stream.
+	Kind string `json:"kind"`
+	// Data is the output of a "stdout" or "stderr" frame.
+	Data []byte `json:"data,omitempty"`
+
+	// Error, ExitCode, Files and Usage are only set in the "exit"
+	// frame. They have the same meaning as in Response.
+	Error    string            `json:"error,omitempty"`
+	ExitCode int               `json:"exitCode,omitempty"`
+	Files    map[string][]byte `json:"files,omitempty"`
+	Usage    *Usage            `json:"usage,omitempty"`
 }
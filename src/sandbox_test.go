@@ -12,6 +12,8 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 // TestIsTest verifies that the isTest helper function matches
@@ -181,3 +183,20 @@ func Benchmark1IsABenchmark(b *testing.B) {
 func BenchmarkisNotABenchmark(b *testing.B) {
 	panic("This is not a valid benchmark function.")
 }
+
+func TestStripHiddenOutput(t *testing.T) {
+	events := []Event{
+		{Message: "=== RUN   TestAbs\n--- PASS: TestAbs (0.00s)\n", Kind: "stdout"},
+		{Message: "oops\n", Kind: "stderr"},
+		{Message: "=== RUN   playgroundProfile\n--- PASS: playgroundProfile (0.00s)\n=== RUN   playgroundCoverage\n", Kind: "stdout"},
+		{Message: "--- PASS: playgroundCoverage (0.00s)\nPASS\n", Kind: "stdout"},
+	}
+	want := []Event{
+		{Message: "=== RUN   TestAbs\n--- PASS: TestAbs (0.00s)\n", Kind: "stdout"},
+		{Message: "oops\n", Kind: "stderr"},
+		{Message: "PASS\n", Kind: "stdout"},
+	}
+	if diff := cmp.Diff(want, stripHiddenOutput(events)); diff != "" {
+		t.Errorf("stripHiddenOutput mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -6,11 +6,15 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"io"
 	"net/http"
 	"time"
+
+	"golang.org/x/net/websocket"
 )
 
 type server struct {
@@ -19,6 +23,18 @@ type server struct {
 	log      logger
 	cache    responseCache
 	examples *examplesHandler
+	jobs     *jobStore
+	limiter  *rateLimiter // nil means requests are not rate limited
+	goproxy  *moduleProxy // nil means no module proxy is served
+
+	// runSocket builds and runs the programs of /socket; it is
+	// compileAndRunObserved but in tests.
+	runSocket func(ctx context.Context, req *request, obs *runObserver) (*response, error)
+
+	// editTemplate is parsed in newServer rather than at
+	// initialization, since the binary also runs as a vet tool in
+	// other directories; see runVettool.
+	editTemplate *template.Template
 
 	// When the executable was last modified. Used for caching headers of compiled assets.
 	modtime time.Time
@@ -40,19 +56,37 @@ func newServer(options ...func(s *server) error) (*server, error) {
 	if s.examples == nil {
 		return nil, fmt.Errorf("must provide an option func that sets the examples handler")
 	}
+	t, err := template.ParseFiles("edit.html")
+	if err != nil {
+		return nil, err
+	}
+	s.editTemplate = t
 	s.init()
 	return s, nil
 }
 
 func (s *server) init() {
+	s.jobs = newJobStore()
+	s.runSocket = compileAndRunObserved
 	s.mux.HandleFunc("/", s.handleEdit)
-	s.mux.HandleFunc("/fmt", s.handleFmt)
+	s.mux.Handle("/fmt", s.limit(budgetFmt, http.HandlerFunc(s.handleFmt)))
+	s.mux.Handle("/fix", s.limit(budgetFmt, http.HandlerFunc(s.handleFix)))
 	s.mux.HandleFunc("/version", s.handleVersion)
-	s.mux.HandleFunc("/vet", s.commandHandler("vet", vetCheck))
-	s.mux.HandleFunc("/compile", s.commandHandler("prog", compileAndRun))
-	s.mux.HandleFunc("/share", s.handleShare)
+	s.mux.Handle("/vet", s.limit(budgetVet, s.commandHandler("vet", vetCheck)))
+	s.mux.Handle("/compile", s.limit(budgetCompile, s.commandHandler("prog", compileAndRun)))
+	s.mux.Handle("/build", s.limit(budgetCompile, s.commandHandler("build", compileOnly)))
+	s.mux.Handle("/asm", s.limit(budgetCompile, s.commandHandler("asm", compileAsm)))
+	s.mux.Handle("/escape", s.limit(budgetCompile, s.commandHandler("escape", compileEscape)))
+	s.mux.Handle("/ssa", s.limit(budgetCompile, s.commandHandler("ssa", compileSSA)))
+	s.mux.Handle("/socket", websocket.Handler(s.handleSocket))
+	s.mux.Handle("/jobs", s.limit(budgetCompile, http.HandlerFunc(s.handleJobs)))
+	s.mux.Handle("/jobs/", s.limit(budgetPoll, http.HandlerFunc(s.handleJobs)))
+	s.mux.Handle("/share", s.limit(budgetShare, http.HandlerFunc(s.handleShare)))
 	s.mux.HandleFunc("/favicon.ico", handleFavicon)
 	s.mux.HandleFunc("/_ah/health", s.handleHealthCheck)
+	if vulnDB != "" {
+		s.mux.Handle("/vulncheck", s.limit(budgetCompile, http.HandlerFunc(s.handleVulncheck)))
+	}
 
 	staticHandler := http.StripPrefix("/static/", http.FileServer(http.Dir("./static")))
 	s.mux.Handle("/static/", staticHandler)
@@ -41,7 +41,7 @@ func testingOptions(t *testing.T) func(s *server) error {
 		s.db = &inMemStore{}
 		s.log = testLogger{t}
 		var err error
-		s.examples, err = newExamplesHandler(false, time.Now())
+		s.examples, err = newExamplesHandler(time.Now())
 		if err != nil {
 			return err
 		}
@@ -169,7 +169,7 @@ func TestCommandHandler(t *testing.T) {
 		s.log = newStdLogger()
 		s.cache = new(inMemCache)
 		var err error
-		s.examples, err = newExamplesHandler(false, time.Now())
+		s.examples, err = newExamplesHandler(time.Now())
 		if err != nil {
 			return err
 		}
@@ -206,6 +206,9 @@ func TestCommandHandler(t *testing.T) {
 		if r.Body == "run-timeout-error" {
 			return &response{Errors: runTimeoutError}, nil
 		}
+		if r.Body == "queue-full" {
+			return nil, fmt.Errorf("sandboxBuild: %w", errBuildQueueFull)
+		}
 		resp := &response{Events: []Event{{r.Body, "stdout", 0}}}
 		return resp, nil
 	})
@@ -222,6 +225,9 @@ func TestCommandHandler(t *testing.T) {
 		{"GET request", http.MethodGet, http.StatusBadRequest, nil, nil, false},
 		{"Empty POST", http.MethodPost, http.StatusBadRequest, nil, nil, false},
 		{"Failed cmdFunc", http.MethodPost, http.StatusInternalServerError, []byte(`{"Body":"fail"}`), nil, false},
+		{"Build queue full", http.MethodPost, http.StatusTooManyRequests, []byte(`{"Body":"queue-full"}`), nil, false},
+		{"Analyzer not enabled", http.MethodPost, http.StatusBadRequest, []byte(`{"Body":"analyzed","Analyzers":["nosuch"]}`), nil, false},
+		{"Unknown profile", http.MethodPost, http.StatusBadRequest, []byte(`{"Body":"profiled","Profile":"block"}`), nil, false},
 		{"Standard flow", http.MethodPost, http.StatusOK,
 			[]byte(`{"Body":"ok"}`),
 			[]byte(`{"Errors":"","Events":[{"Message":"ok","Kind":"stdout","Delay":0}],"Status":0,"IsTest":false,"TestsFailed":0}
@@ -300,34 +306,83 @@ func TestCommandHandler(t *testing.T) {
 	}
 }
 
+func TestCommandHandlerCanceled(t *testing.T) {
+	s, err := newServer(func(s *server) error {
+		s.db = &inMemStore{}
+		s.log = newStdLogger()
+		s.cache = new(inMemCache)
+		var err error
+		s.examples, err = newExamplesHandler(time.Now())
+		return err
+	})
+	if err != nil {
+		t.Fatalf("newServer: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	testHandler := s.commandHandler("test", func(ctx context.Context, r *request) (*response, error) {
+		cancel() // the user kills the program
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	req := httptest.NewRequest(http.MethodPost, "/compile", bytes.NewReader([]byte(`{"Body":"canceled"}`))).WithContext(ctx)
+	w := httptest.NewRecorder()
+	testHandler(w, req)
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Errorf("got status code %d; want %d", got, want)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("got body %q; want none", w.Body)
+	}
+	if err := s.cache.Get(cacheKey("test", "canceled"), new(response)); err == nil {
+		t.Errorf("canceled response was cached")
+	}
+}
+
 func TestPlaygroundGoproxy(t *testing.T) {
-	const envKey = "PLAY_GOPROXY"
-	defer os.Setenv(envKey, os.Getenv(envKey))
+	for _, key := range []string{"PLAY_GOPROXY", "PLAY_GOPROXY_FALLBACK", "PLAY_GOSUMDB", "GOPRIVATE", "GONOSUMDB"} {
+		defer os.Setenv(key, os.Getenv(key))
+	}
+	defer func(url string, mods []string) { localGoproxy, localModules = url, mods }(localGoproxy, localModules)
 
+	const local = "http://127.0.0.1:8080"
 	tests := []struct {
-		name string
-		env  string
-		want string
+		name        string
+		env         string
+		fallback    string
+		local       string
+		private     string
+		want        string
+		wantSumdb   string
+		wantNosumdb string
 	}{
-		{name: "missing", env: "", want: "https://proxy.golang.org"},
+		{name: "missing", env: "", want: "https://goproxy.cn"},
 		{name: "set_to_default", env: "https://proxy.golang.org", want: "https://proxy.golang.org"},
 		{name: "changed", env: "https://company.intranet", want: "https://company.intranet"},
+		{name: "local", env: "", local: local, want: local, wantSumdb: "off", wantNosumdb: "example.com/a,example.com/b"},
+		{name: "local_with_fallback", env: "", fallback: "https://proxy.golang.org", local: local, want: local + ",https://proxy.golang.org", wantNosumdb: "example.com/a,example.com/b"},
+		{name: "local_with_private", env: "", fallback: "https://proxy.golang.org", local: local, private: "git.example.com", want: local + ",https://proxy.golang.org", wantNosumdb: "git.example.com,example.com/a,example.com/b"},
+		{name: "changed_with_local", env: "https://company.intranet", local: local, want: "https://company.intranet"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if tt.env != "" {
-				if err := os.Setenv(envKey, tt.env); err != nil {
+			localGoproxy, localModules = tt.local, nil
+			if tt.local != "" {
+				localModules = []string{"example.com/a", "example.com/b"}
+			}
+			for key, v := range map[string]string{"PLAY_GOPROXY": tt.env, "PLAY_GOPROXY_FALLBACK": tt.fallback, "PLAY_GOSUMDB": "", "GOPRIVATE": tt.private, "GONOSUMDB": ""} {
+				if err := os.Setenv(key, v); err != nil {
 					t.Errorf("unable to set environment variable for test: %s", err)
 				}
-			} else {
-				if err := os.Unsetenv(envKey); err != nil {
-					t.Errorf("unable to unset environment variable for test: %s", err)
-				}
 			}
-			got := playgroundGoproxy()
-			if got != tt.want {
+			if got := playgroundGoproxy(); got != tt.want {
 				t.Errorf("playgroundGoproxy = %s; want %s; env: %s", got, tt.want, tt.env)
 			}
+			if got := playgroundGosumdb(); got != tt.wantSumdb {
+				t.Errorf("playgroundGosumdb = %q; want %q", got, tt.wantSumdb)
+			}
+			if got := playgroundGonosumdb(); got != tt.wantNosumdb {
+				t.Errorf("playgroundGonosumdb = %q; want %q", got, tt.wantNosumdb)
+			}
 		})
 	}
 }
@@ -17,10 +17,12 @@ const (
 	// This salt is not meant to be kept secret (it’s checked in after all). It’s
 	// a tiny bit of paranoia to avoid whatever problems a collision may cause.
 	salt = "Go playground salt\n"
-
-	maxSnippetSize = 64 * 1024
 )
 
+// maxSnippetSize is the maximum size of a shared snippet, not counting
+// vendored files. It is set in main from the configuration.
+var maxSnippetSize = 64 * 1024
+
 type snippet struct {
 	// Body []byte `datastore:",noindex"` // golang.org/issues/23253
 	Body []byte
@@ -83,14 +85,16 @@ func (s *server) handleShare(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var body bytes.Buffer
-	_, err := io.Copy(&body, io.LimitReader(r.Body, maxSnippetSize+1))
+	_, err := io.Copy(&body, io.LimitReader(r.Body, int64(maxSnippetSize+maxVendorSize+1)))
 	r.Body.Close()
 	if err != nil {
 		s.log.Errorf("reading Body: %v", err)
 		http.Error(w, "Server Error", http.StatusInternalServerError)
 		return
 	}
-	if body.Len() > maxSnippetSize {
+	// Vendored files count against maxVendorSize instead, as checked
+	// when the snippet is built.
+	if body.Len() > maxSnippetSize+maxVendorSize || snippetSize(body.Bytes()) > maxSnippetSize {
 		http.Error(w, "Snippet is too large", http.StatusRequestEntityTooLarge)
 		return
 	}
@@ -0,0 +1,290 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// maxSocketMessageSize bounds the size of a single message
+	// received on /socket.
+	maxSocketMessageSize = 1 << 20
+	// maxSocketPrograms bounds the number of programs a single
+	// /socket connection may be building or running at once.
+	maxSocketPrograms = 4
+)
+
+// socketMessage is the JSON message exchanged with the SocketTransport
+// of playground.js over /socket.
+type socketMessage struct {
+	Id      string         // client-provided unique id for the program
+	Kind    string         // in: "run", "kill"; out: "status", "stdout", "stderr", "system", "end"
+	Body    string         // program source for "run"; output, status ("queued N", "building", "running") or exit message otherwise
+	Options *socketOptions `json:",omitempty"`
+}
+
+// socketOptions are the options of a "run" socketMessage.
+type socketOptions struct {
+	WithVet bool
+}
+
+// handleSocket serves a WebSocket connection on /socket. Clients send
+// "run" messages to build and run a program and "kill" messages to
+// stop it; the server replies with the build status and the program
+// output as it happens, ending with an "end" or "system" message for
+// each program. Closing the connection kills all its programs.
+//
+// Unlike /compile, results are not cached: the point of the socket is
+// to report progress live.
+func (s *server) handleSocket(conn *websocket.Conn) {
+	defer conn.Close()
+	conn.MaxPayloadBytes = maxSocketMessageSize
+
+	ctx, cancel := context.WithCancel(conn.Request().Context())
+	defer cancel()
+
+	// Each "run" message counts against the client's compile budget,
+	// like a /compile request.
+	var client string
+	if s.limiter != nil {
+		var err error
+		if client, err = s.limiter.client(conn.Request()); err != nil {
+			s.log.Printf("socket: %v", err)
+			return
+		}
+	}
+
+	var (
+		mu      sync.Mutex                        // guards running and writes to conn
+		running = map[string]context.CancelFunc{} // Id -> cancel
+		wg      sync.WaitGroup
+	)
+	defer wg.Wait()
+	send := func(m *socketMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := websocket.JSON.Send(conn, m); err != nil {
+			s.log.Printf("socket: error sending message: %v", err)
+			cancel()
+		}
+	}
+
+	for {
+		var m socketMessage
+		if err := websocket.JSON.Receive(conn, &m); err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				s.log.Printf("socket: error receiving message: %v", err)
+			}
+			return
+		}
+		switch m.Kind {
+		case "run":
+			if s.limiter != nil {
+				ok, _ := s.limiter.allow(budgetCompile, client)
+				recordRateLimit(ctx, budgetCompile, rateLimitOutcome(ok))
+				if !ok {
+					go send(&socketMessage{Id: m.Id, Kind: "end", Body: "too many requests"})
+					continue
+				}
+			}
+			mu.Lock()
+			_, dup := running[m.Id]
+			tooMany := len(running) >= maxSocketPrograms
+			var runCtx context.Context
+			if !dup && !tooMany {
+				var runCancel context.CancelFunc
+				runCtx, runCancel = context.WithCancel(ctx)
+				running[m.Id] = runCancel
+			}
+			mu.Unlock()
+			if dup {
+				go send(&socketMessage{Id: m.Id, Kind: "end", Body: "program already running"})
+				continue
+			}
+			if tooMany {
+				go send(&socketMessage{Id: m.Id, Kind: "end", Body: "too many programs running"})
+				continue
+			}
+			wg.Add(1)
+			go func(m socketMessage) {
+				defer wg.Done()
+				s.runSocketProgram(runCtx, &m, send)
+				mu.Lock()
+				cancel := running[m.Id]
+				delete(running, m.Id)
+				mu.Unlock()
+				cancel()
+			}(m)
+		case "kill":
+			mu.Lock()
+			if cancel, ok := running[m.Id]; ok {
+				cancel()
+			}
+			mu.Unlock()
+		default:
+			s.log.Printf("socket: unknown message kind %q", m.Kind)
+		}
+	}
+}
+
+// runSocketProgram builds and runs the program in the "run" message m,
+// reporting its progress and output with send. Output events are sent
+// while the program runs, each after its recorded delay, so that the
+// client sees the program's writes paced as they happened in
+// playground time.
+func (s *server) runSocketProgram(ctx context.Context, m *socketMessage, send func(*socketMessage)) {
+	out := func(kind, body string) {
+		send(&socketMessage{Id: m.Id, Kind: kind, Body: body})
+	}
+	req := &request{Body: m.Body}
+	if m.Options != nil {
+		req.WithVet = m.Options.WithVet
+	}
+	pacer := newEventPacer(ctx, func(e Event) { out(e.Kind, e.Message) })
+	obs := &runObserver{
+		status: func(stage string) { out("status", stage) },
+		queued: func(position int) {
+			// The "building" status was sent before the build
+			// was queued.
+			if position > 0 {
+				out("status", fmt.Sprintf("queued %d", position))
+			}
+		},
+		event: pacer.add,
+	}
+	resp, err := s.runSocket(ctx, req, obs)
+	pacer.close()
+	if ctx.Err() != nil {
+		out("end", "killed")
+		return
+	}
+	if errors.Is(err, errBuildQueueFull) {
+		out("stderr", "Too many programs are being built; try again later.")
+		out("end", "")
+		return
+	}
+	if err != nil {
+		s.log.Errorf("socket: compileAndRun error: %v", err)
+		out("stderr", "Error communicating with remote server.")
+		out("end", "")
+		return
+	}
+	if resp.Errors != "" && len(resp.Events) == 0 {
+		out("stderr", resp.Errors)
+		out("system", "\nGo build failed.")
+		return
+	}
+	if resp.VetErrors != "" {
+		out("stderr", resp.VetErrors)
+		out("system", "\nGo vet failed.\n\n")
+	}
+	switch {
+	case resp.IsTest && resp.TestsFailed > 0:
+		plural := ""
+		if resp.TestsFailed > 1 {
+			plural = "s"
+		}
+		out("system", fmt.Sprintf("\n%d test%s failed.", resp.TestsFailed, plural))
+	case resp.IsTest:
+		out("system", "\nAll tests passed.")
+	case resp.Status > 0:
+		out("end", fmt.Sprintf("status %d.", resp.Status))
+	case resp.Errors != "":
+		out("end", resp.Errors+".")
+	default:
+		out("end", "")
+	}
+}
+
+// eventPacer sends the Events of a program's output, each after its
+// recorded delay, in the background: adding an Event never waits, so
+// the program's output is read from the sandbox as it is produced.
+type eventPacer struct {
+	send func(Event)
+	wake chan struct{} // signaled when an Event is added, or on close
+	done chan struct{} // closed when the pacer stops
+
+	mu     sync.Mutex
+	queue  []Event
+	closed bool
+}
+
+// newEventPacer returns an eventPacer sending Events with send until
+// ctx is done.
+func newEventPacer(ctx context.Context, send func(Event)) *eventPacer {
+	p := &eventPacer{
+		send: send,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	go p.loop(ctx)
+	return p
+}
+
+// add queues e for sending.
+func (p *eventPacer) add(e Event) {
+	p.mu.Lock()
+	p.queue = append(p.queue, e)
+	p.mu.Unlock()
+	p.signal()
+}
+
+// close waits until the queued Events are sent, or the pacer's context
+// is done. No Events may be added after close.
+func (p *eventPacer) close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.signal()
+	<-p.done
+}
+
+func (p *eventPacer) signal() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *eventPacer) loop(ctx context.Context) {
+	defer close(p.done)
+	for {
+		p.mu.Lock()
+		if len(p.queue) == 0 {
+			closed := p.closed
+			p.mu.Unlock()
+			if closed {
+				return
+			}
+			select {
+			case <-p.wake:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		e := p.queue[0]
+		p.queue = p.queue[1:]
+		p.mu.Unlock()
+		if e.Delay > 0 {
+			t := time.NewTimer(e.Delay)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return
+			}
+		}
+		p.send(e)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/net/websocket"
+)
+
+func TestSocket(t *testing.T) {
+	if _, err := os.Stat("/usr/local/go-faketime/bin/go"); err == nil {
+		t.Skip("test expects the playground toolchain to be missing")
+	}
+	s, err := newServer(testingOptions(t))
+	if err != nil {
+		t.Fatalf("newServer(testingOptions(t)): %v", err)
+	}
+	s.log = newStdLogger() // the build is expected to fail in tests
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/socket"
+	conn, err := websocket.Dial(url, "", ts.URL)
+	if err != nil {
+		t.Fatalf("websocket.Dial(%q): %v", url, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	send := func(m socketMessage) {
+		t.Helper()
+		if err := websocket.JSON.Send(conn, m); err != nil {
+			t.Fatalf("websocket.JSON.Send(%+v): %v", m, err)
+		}
+	}
+	// Killing an unknown program is ignored.
+	send(socketMessage{Id: "0", Kind: "kill"})
+	send(socketMessage{Id: "1", Kind: "run", Body: healthProg})
+
+	var kinds []string
+	for {
+		var m socketMessage
+		if err := websocket.JSON.Receive(conn, &m); err != nil {
+			t.Fatalf("websocket.JSON.Receive: %v", err)
+		}
+		if m.Id != "1" {
+			t.Fatalf("got message for program %q; want %q", m.Id, "1")
+		}
+		kinds = append(kinds, m.Kind)
+		if m.Kind == "end" || m.Kind == "system" {
+			break
+		}
+	}
+	// There is no sandbox in tests, so the build is expected to fail
+	// without a user-visible reason.
+	if got, want := strings.Join(kinds, ","), "status,stderr,end"; got != want {
+		t.Errorf("got message kinds %s; want %s", got, want)
+	}
+}
+
+// TestSocketPrograms drives /socket with a fake runner, whose programs
+// write their body and, if it starts with "wait", run until killed.
+func TestSocketPrograms(t *testing.T) {
+	s, err := newServer(testingOptions(t))
+	if err != nil {
+		t.Fatalf("newServer(testingOptions(t)): %v", err)
+	}
+	s.log = newStdLogger() // the connection is closed abruptly
+	s.runSocket = func(ctx context.Context, req *request, obs *runObserver) (*response, error) {
+		obs.status("running")
+		obs.event(Event{req.Body, "stdout", 0})
+		if strings.HasPrefix(req.Body, "wait") {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return &response{Events: []Event{{req.Body, "stdout", 0}}}, nil
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/socket"
+	conn, err := websocket.Dial(url, "", ts.URL)
+	if err != nil {
+		t.Fatalf("websocket.Dial(%q): %v", url, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	send := func(m socketMessage) {
+		t.Helper()
+		if err := websocket.JSON.Send(conn, m); err != nil {
+			t.Fatalf("websocket.JSON.Send(%+v): %v", m, err)
+		}
+	}
+	got := map[string][]string{} // Id -> "kind body" of its messages
+	// recvUntil receives messages until the given one.
+	recvUntil := func(id, kind, body string) {
+		t.Helper()
+		for {
+			var m socketMessage
+			if err := websocket.JSON.Receive(conn, &m); err != nil {
+				t.Fatalf("websocket.JSON.Receive waiting for %s %q of %s: %v", kind, body, id, err)
+			}
+			got[m.Id] = append(got[m.Id], m.Kind+" "+m.Body)
+			if m.Id == id && m.Kind == kind && m.Body == body {
+				return
+			}
+		}
+	}
+
+	// A program that completes.
+	send(socketMessage{Id: "done", Kind: "run", Body: "hello"})
+	recvUntil("done", "end", "")
+
+	// The output of running programs is streamed live.
+	ids := []string{"w1", "w2", "w3", "w4"}
+	for _, id := range ids {
+		send(socketMessage{Id: id, Kind: "run", Body: "wait " + id})
+		recvUntil(id, "stdout", "wait "+id)
+	}
+	// A connection runs at most maxSocketPrograms programs at once,
+	// each with a distinct Id.
+	send(socketMessage{Id: "w5", Kind: "run", Body: "wait w5"})
+	recvUntil("w5", "end", "too many programs running")
+	send(socketMessage{Id: "w1", Kind: "run", Body: "wait again"})
+	recvUntil("w1", "end", "program already running")
+
+	// Killed programs end.
+	for _, id := range ids {
+		send(socketMessage{Id: id, Kind: "kill"})
+		recvUntil(id, "end", "killed")
+	}
+
+	want := map[string][]string{
+		"done": {"status running", "stdout hello", "end "},
+		"w1":   {"status running", "stdout wait w1", "end program already running", "end killed"},
+		"w2":   {"status running", "stdout wait w2", "end killed"},
+		"w3":   {"status running", "stdout wait w3", "end killed"},
+		"w4":   {"status running", "stdout wait w4", "end killed"},
+		"w5":   {"end too many programs running"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("messages mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestEventPacer(t *testing.T) {
+	var got []Event
+	p := newEventPacer(context.Background(), func(e Event) { got = append(got, e) })
+	start := time.Now()
+	p.add(Event{Message: "a", Kind: "stdout"})
+	p.add(Event{Message: "b", Kind: "stderr", Delay: 50 * time.Millisecond})
+	if d := time.Since(start); d > 25*time.Millisecond {
+		t.Errorf("adding events took %v; want no wait", d)
+	}
+	p.close()
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("events sent in %v; want at least their delay", d)
+	}
+	want := []Event{{Message: "a", Kind: "stdout"}, {Message: "b", Kind: "stderr", Delay: 50 * time.Millisecond}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("sent %v; want %v", got, want)
+	}
+
+	// Canceling stops the pacer without sending the delayed events.
+	ctx, cancel := context.WithCancel(context.Background())
+	got = nil
+	p = newEventPacer(ctx, func(e Event) { got = append(got, e) })
+	p.add(Event{Message: "late", Kind: "stdout", Delay: time.Hour})
+	cancel()
+	p.close()
+	if len(got) != 0 {
+		t.Errorf("canceled pacer sent %v", got)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ssatoolEnv is the environment variable that makes the server binary
+// run as the -toolexec program of the builds of compileSSA instead,
+// with the GOSSAFUNC value; see runSSATool.
+const ssatoolEnv = "PLAY_SSATOOL"
+
+// ssaDirFlag is the compiler flag, removed by runSSATool, with which
+// compileSSA selects the packages to dump the SSA of and the directory
+// to write it to.
+const ssaDirFlag = "-playssadir"
+
+// compileSSA builds the user program in req.Body with GOSSAFUNC set to
+// req.Func and returns the ssa.html page the compiler writes for it in
+// *response.SSA. The page is written to a directory inside the build's
+// temporary directory, so it is private to this request and removed
+// with it. The program is not run.
+func compileSSA(ctx context.Context, req *request) (*response, error) {
+	fn, ok := ssaFunc(req.Func)
+	if !ok {
+		return &response{Errors: fmt.Sprintf("invalid function name %q", req.Func)}, nil
+	}
+
+	tmpDir, err := ioutil.TempDir("", "sandbox")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ssaDir := filepath.Join(tmpDir, "ssa")
+	if err := os.Mkdir(ssaDir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating ssa directory: %v", err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("error finding ssa tool: %v", err)
+	}
+	// The go command replays the output of cached compilations, but
+	// not the files they wrote, so the compiler must run every time
+	// for the user's packages: ssaDir, unique to this request, is in
+	// their compiler flags and so in their cache keys. The other
+	// packages come from the shared cache; see runSSATool.
+	br, err := sandboxBuild(ctx, tmpDir, []byte(req.Body), &buildOptions{
+		env:      []string{ssatoolEnv + "=" + fn},
+		gcflags:  ssaDirFlag + "=" + ssaDir,
+		toolexec: exe,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if br.errorMessage != "" {
+		return &response{Errors: br.errorMessage, CompileErrors: br.compileErrors}, nil
+	}
+	defer br.cleanup()
+
+	// The compiler names the page after the function and its
+	// ABI, as in "main.main,1.html", below a directory for each
+	// package. Generic functions may produce several pages; use
+	// the first one.
+	var pages []string
+	filepath.Walk(ssaDir, func(path string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() && strings.HasSuffix(path, ".html") {
+			pages = append(pages, path)
+		}
+		return nil
+	})
+	if len(pages) == 0 {
+		return &response{Errors: fmt.Sprintf("no function matching %q was compiled", fn)}, nil
+	}
+	sort.Strings(pages)
+	html, err := ioutil.ReadFile(pages[0])
+	if err != nil {
+		return nil, fmt.Errorf("error reading ssa.html: %v", err)
+	}
+	return &response{IsTest: br.testParam != "", SSA: string(html)}, nil
+}
+
+// ssaFunc validates the GOSSAFUNC value requested by the user and
+// qualifies a bare function name with the main package, so that
+// functions of the same name in the standard library are not dumped
+// as well.
+func ssaFunc(fn string) (string, bool) {
+	if fn == "" || len(fn) > 200 || strings.IndexFunc(fn, isBogusSSAFuncRune) != -1 {
+		return "", false
+	}
+	if token.IsIdentifier(fn) {
+		fn = "main." + fn
+	}
+	return fn, true
+}
+
+// isBogusSSAFuncRune reports whether r cannot appear in a GOSSAFUNC
+// value naming a function, method or generic instantiation.
+func isBogusSSAFuncRune(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsControl(r) || r == '=' || r == '/' || r == '\\'
+}
+
+// runSSATool runs a build tool, as the -toolexec program of the builds
+// of compileSSA, if the ssatoolEnv environment variable is set. In that
+// case it does not return.
+//
+// The go command makes GOSSAFUNC and GOSSADIR part of the cache key of
+// every package, so setting them for the whole build would compile the
+// standard library anew each time. Instead, they are only set for the
+// compilations given ssaDirFlag, which compileSSA adds to the flags of
+// the user's packages.
+func runSSATool() {
+	fn := os.Getenv(ssatoolEnv)
+	if fn == "" || len(os.Args) < 2 {
+		return
+	}
+	cmd := ssaToolCommand(fn, os.Args[1], os.Args[2:])
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		if err, ok := err.(*exec.ExitError); ok {
+			os.Exit(err.ExitCode())
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// ssaToolCommand returns the command running tool with args, setting
+// GOSSAFUNC to fn and GOSSADIR to the directory of ssaDirFlag, which is
+// removed from args, if present.
+func ssaToolCommand(fn, tool string, args []string) *exec.Cmd {
+	cmd := exec.Command(tool)
+	cmd.Env = os.Environ()
+	for _, arg := range args {
+		if dir, ok := strings.CutPrefix(arg, ssaDirFlag+"="); ok {
+			cmd.Env = append(cmd.Env, "GOSSAFUNC="+fn, "GOSSADIR="+dir)
+			continue
+		}
+		cmd.Args = append(cmd.Args, arg)
+	}
+	return cmd
+}
@@ -0,0 +1,77 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSSAFunc(t *testing.T) {
+	for _, tt := range []struct {
+		in     string
+		want   string
+		wantOK bool
+	}{
+		{"main", "main.main", true},
+		{"fib", "main.fib", true},
+		{"main.fib", "main.fib", true},
+		{"(*T).String", "(*T).String", true},
+		{"Map[go.shape.int]", "Map[go.shape.int]", true},
+		{"", "", false},
+		{"main fib", "", false},
+		{"fib\nGOFLAGS=-x", "", false},
+		{"a=b", "", false},
+		{"../../etc/passwd", "", false},
+	} {
+		got, ok := ssaFunc(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ssaFunc(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// TestSSATool checks that builds through runSSATool dump the SSA of the
+// user's package every time, even with a warm build cache.
+func TestSSATool(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, goCache := t.TempDir(), t.TempDir()
+	for name, data := range map[string]string{
+		"go.mod":  "module play\n",
+		"main.go": "package main\n\nfunc fib(n int) int {\n\tif n < 2 {\n\t\treturn n\n\t}\n\treturn fib(n-1) + fib(n-2)\n}\n\nfunc main() { println(fib(10)) }\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		ssaDir := t.TempDir()
+		cmd := exec.Command("go", "build", "-o", filepath.Join(dir, "a.out"), "-toolexec="+exe, "-gcflags=play/...="+ssaDirFlag+"="+ssaDir, ".")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOCACHE="+goCache, "GOFLAGS=", ssatoolEnv+"=main.fib")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("build %d: %v\n%s", i, err, out)
+		}
+		var pages []string
+		filepath.Walk(ssaDir, func(path string, fi os.FileInfo, err error) error {
+			if err == nil && strings.HasSuffix(path, ".html") {
+				pages = append(pages, filepath.Base(path))
+			}
+			return nil
+		})
+		if len(pages) != 1 || !strings.HasPrefix(pages[0], "main.fib") {
+			t.Errorf("build %d: got SSA pages %v, want main.fib's", i, pages)
+		}
+	}
+}
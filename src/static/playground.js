@@ -129,7 +129,7 @@ function HTTPTransport(enableVet) {
       seq++;
       var cur = seq;
       var playing;
-      $.ajax('/compile?backend=' + (options.backend || ''), {
+      var xhr = $.ajax('/compile?backend=' + (options.backend || ''), {
         type: 'POST',
         data: { version: 2, body: body, withVet: enableVet },
         dataType: 'json',
@@ -148,12 +148,16 @@ function HTTPTransport(enableVet) {
           }
           playing = playback(output, data);
         },
-        error: function() {
+        error: function(jqXHR, textStatus) {
+          if (textStatus === 'abort') return;
           error(output, 'Error communicating with remote server.');
         },
       });
       return {
         Kill: function() {
+          // Aborting the request lets the server stop building or
+          // running the program and free its sandbox.
+          xhr.abort();
           if (playing != null) playing.Stop();
           output({ Kind: 'end', Body: 'killed' });
         },
@@ -183,6 +187,8 @@ function SocketTransport() {
     var m = JSON.parse(e.data);
     var output = outputs[m.Id];
     if (output === null) return;
+    // Status messages report build progress, not program output.
+    if (m.Kind == 'status') return;
     if (!started[m.Id]) {
       output({ Kind: 'start' });
       started[m.Id] = true;
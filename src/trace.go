@@ -0,0 +1,278 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/trace"
+)
+
+// A traced program writes a runtime/trace execution trace to traceFile
+// in the output directory of the sandbox, from the initialization of
+// the main package until main returns, as a profile is written; see
+// addProfiling.
+//
+// The trace's clock is the CPU's, not the program's fake clock, so
+// time.Sleep and timers take no time in the timeline: goroutines that
+// sleep wake up as soon as every other goroutine is blocked.
+const traceFile = "trace.out"
+
+// maxTraceSpans is the maximum number of goroutine spans in a
+// traceTimeline.
+const maxTraceSpans = 20000
+
+// maxTraceMinor is the minor version of the latest Go release whose
+// execution traces golang.org/x/exp/trace, at the version required in
+// go.mod, can read. Traces change format with Go releases, so it must
+// be at least that of the playground's toolchain, GO_VERSION in the
+// Dockerfiles (Go 1.23.4), and x/exp must be upgraded with it when the
+// toolchain moves to a release with a new trace format.
+const maxTraceMinor = 23
+
+// errTraceVersion is returned by summarizeTrace for the traces of Go
+// releases newer than Go 1.maxTraceMinor.
+var errTraceVersion = fmt.Errorf("execution traces are only supported up to go 1.%d", maxTraceMinor)
+
+// traceTimeline is the timeline of the goroutines of a program and of
+// the processors (Ps) running them, summarized from its execution
+// trace, for drawing. Times are in nanoseconds since the first event
+// of the trace.
+type traceTimeline struct {
+	Duration   int64               `json:"duration"`
+	Goroutines []goroutineTimeline `json:"goroutines"`
+	Procs      []procTimeline      `json:"procs"`
+	// Truncated reports whether spans were dropped, beyond
+	// maxTraceSpans.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// goroutineTimeline is the states of a goroutine over time.
+type goroutineTimeline struct {
+	ID int64 `json:"id"`
+	// Function is the function the goroutine started with, if known;
+	// it is "main.main" for the main goroutine.
+	Function string `json:"function,omitempty"`
+	// System reports whether the goroutine belongs to the runtime or
+	// to the tracer.
+	System bool            `json:"system,omitempty"`
+	Spans  []goroutineSpan `json:"spans"`
+}
+
+// goroutineSpan is a period during which a goroutine is in the same
+// state: "runnable", "running", "waiting" or "syscall".
+type goroutineSpan struct {
+	Start int64  `json:"start"`
+	End   int64  `json:"end"`
+	State string `json:"state"`
+	// Reason is why the goroutine is waiting, such as "chan receive"
+	// or "sleep", if known.
+	Reason string `json:"reason,omitempty"`
+	// Proc is the processor running the goroutine, in the running
+	// state only.
+	Proc int64 `json:"proc"`
+}
+
+// procTimeline is the goroutines run by a processor over time.
+type procTimeline struct {
+	ID    int64      `json:"id"`
+	Spans []procSpan `json:"spans"`
+}
+
+// procSpan is a period during which a processor runs a goroutine.
+type procSpan struct {
+	Start     int64 `json:"start"`
+	End       int64 `json:"end"`
+	Goroutine int64 `json:"goroutine"`
+}
+
+// summarizeTrace summarizes the execution trace in data. It returns nil
+// if there is no data, as when the program exited before writing it.
+func summarizeTrace(data []byte) (*traceTimeline, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	// The header of a trace is "go 1.N trace" padded with NUL bytes.
+	header := data
+	if len(header) > 16 {
+		header = header[:16]
+	}
+	var minor int
+	if _, err := fmt.Sscanf(string(header), "go 1.%d trace", &minor); err == nil && minor > maxTraceMinor {
+		return nil, fmt.Errorf("%w, not go 1.%d", errTraceVersion, minor)
+	}
+	r, err := trace.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	b := newTimelineBuilder()
+	for {
+		ev, err := r.ReadEvent()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		b.at(int64(ev.Time()))
+		if ev.Kind() != trace.EventStateTransition {
+			continue
+		}
+		st := ev.StateTransition()
+		if st.Resource.Kind != trace.ResourceGoroutine {
+			continue
+		}
+		id := st.Resource.Goroutine()
+		_, to := st.Goroutine()
+		// A new goroutine's stack is its start function; otherwise
+		// the outermost frame of a goroutine's own stack is.
+		fn := outermostFunc(st.Stack)
+		if fn == "" && ev.Goroutine() == id {
+			fn = outermostFunc(ev.Stack())
+		}
+		b.transition(int64(ev.Time()), id, to, st.Reason, fn, ev.Proc())
+	}
+	return b.finish(), nil
+}
+
+// outermostFunc returns the function of the outermost frame of stk.
+func outermostFunc(stk trace.Stack) string {
+	fn := ""
+	stk.Frames(func(f trace.StackFrame) bool {
+		fn = f.Func
+		return true
+	})
+	return fn
+}
+
+// goStateNames are the names of the goroutine states in a
+// goroutineSpan.
+var goStateNames = map[trace.GoState]string{
+	trace.GoRunnable: "runnable",
+	trace.GoRunning:  "running",
+	trace.GoWaiting:  "waiting",
+	trace.GoSyscall:  "syscall",
+}
+
+// timelineBuilder builds a traceTimeline from the state transitions of
+// goroutines, in time order.
+type timelineBuilder struct {
+	tl         traceTimeline
+	start, end int64 // times of the first and last events
+	started    bool
+	spans      int
+	goroutines map[trace.GoID]*goroutineTimeline
+	procs      map[trace.ProcID]*procTimeline
+	// current is the state of each live goroutine, since when.
+	current map[trace.GoID]openSpan
+}
+
+type openSpan struct {
+	state  trace.GoState
+	reason string
+	proc   trace.ProcID
+	since  int64
+}
+
+func newTimelineBuilder() *timelineBuilder {
+	return &timelineBuilder{
+		goroutines: map[trace.GoID]*goroutineTimeline{},
+		procs:      map[trace.ProcID]*procTimeline{},
+		current:    map[trace.GoID]openSpan{},
+	}
+}
+
+// at records an event at time t.
+func (b *timelineBuilder) at(t int64) {
+	if !b.started {
+		b.start, b.started = t, true
+	}
+	b.end = t
+}
+
+// transition records the transition of goroutine id, running fn, to
+// state to at time t for the given reason. proc is the processor on
+// which the event happened.
+func (b *timelineBuilder) transition(t int64, id trace.GoID, to trace.GoState, reason, fn string, proc trace.ProcID) {
+	b.at(t)
+	g := b.goroutines[id]
+	if g == nil {
+		g = &goroutineTimeline{ID: int64(id)}
+		b.goroutines[id] = g
+	}
+	if g.Function == "" && fn != "" {
+		if fn == "runtime.main" {
+			fn = "main.main"
+		}
+		g.Function = fn
+		g.System = strings.HasPrefix(fn, "runtime.") || strings.HasPrefix(fn, "runtime/")
+	}
+	if cur, ok := b.current[id]; ok {
+		b.addSpan(g, cur, t)
+	}
+	if to == trace.GoNotExist {
+		delete(b.current, id)
+		return
+	}
+	if to != trace.GoWaiting {
+		reason = ""
+	}
+	b.current[id] = openSpan{state: to, reason: reason, proc: proc, since: t}
+}
+
+// addSpan adds the span of goroutine g in state s until end.
+func (b *timelineBuilder) addSpan(g *goroutineTimeline, s openSpan, end int64) {
+	name, ok := goStateNames[s.state]
+	if !ok {
+		return
+	}
+	if b.spans >= maxTraceSpans {
+		b.tl.Truncated = true
+		return
+	}
+	b.spans++
+	span := goroutineSpan{Start: s.since - b.start, End: end - b.start, State: name, Reason: s.reason}
+	if s.state == trace.GoRunning && s.proc != trace.NoProc {
+		span.Proc = int64(s.proc)
+		p := b.procs[s.proc]
+		if p == nil {
+			p = &procTimeline{ID: int64(s.proc)}
+			b.procs[s.proc] = p
+		}
+		p.Spans = append(p.Spans, procSpan{Start: span.Start, End: span.End, Goroutine: g.ID})
+	}
+	g.Spans = append(g.Spans, span)
+}
+
+// finish ends the spans of the goroutines still alive at the last
+// event and returns the timeline, sorted by goroutine and processor.
+func (b *timelineBuilder) finish() *traceTimeline {
+	ids := make([]trace.GoID, 0, len(b.current))
+	for id := range b.current {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		b.addSpan(b.goroutines[id], b.current[id], b.end)
+	}
+	b.tl.Duration = b.end - b.start
+	b.tl.Goroutines = []goroutineTimeline{}
+	for _, g := range b.goroutines {
+		if len(g.Spans) > 0 {
+			b.tl.Goroutines = append(b.tl.Goroutines, *g)
+		}
+	}
+	sort.Slice(b.tl.Goroutines, func(i, j int) bool { return b.tl.Goroutines[i].ID < b.tl.Goroutines[j].ID })
+	b.tl.Procs = []procTimeline{}
+	for _, p := range b.procs {
+		b.tl.Procs = append(b.tl.Procs, *p)
+	}
+	sort.Slice(b.tl.Procs, func(i, j int) bool { return b.tl.Procs[i].ID < b.tl.Procs[j].ID })
+	return &b.tl
+}
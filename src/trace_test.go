@@ -0,0 +1,154 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/trace"
+)
+
+func TestTimelineBuilder(t *testing.T) {
+	b := newTimelineBuilder()
+	b.at(1000)
+	b.transition(1000, 1, trace.GoRunning, "", "runtime.main", 0)
+	b.transition(1010, 5, trace.GoRunnable, "", "main.worker", 0)
+	b.transition(1020, 1, trace.GoWaiting, "chan receive", "", 0)
+	b.transition(1020, 5, trace.GoRunning, "", "", 0)
+	b.transition(1025, 6, trace.GoRunnable, "", "runtime.bgsweep", 1)
+	b.transition(1030, 1, trace.GoRunnable, "", "", 0)
+	b.transition(1030, 5, trace.GoNotExist, "", "", 0)
+	b.transition(1035, 1, trace.GoRunning, "", "", 1)
+	b.at(1050)
+	got := b.finish()
+
+	want := &traceTimeline{
+		Duration: 50,
+		Goroutines: []goroutineTimeline{
+			{ID: 1, Function: "main.main", Spans: []goroutineSpan{
+				{Start: 0, End: 20, State: "running"},
+				{Start: 20, End: 30, State: "waiting", Reason: "chan receive"},
+				{Start: 30, End: 35, State: "runnable"},
+				{Start: 35, End: 50, State: "running", Proc: 1},
+			}},
+			{ID: 5, Function: "main.worker", Spans: []goroutineSpan{
+				{Start: 10, End: 20, State: "runnable"},
+				{Start: 20, End: 30, State: "running"},
+			}},
+			{ID: 6, Function: "runtime.bgsweep", System: true, Spans: []goroutineSpan{
+				{Start: 25, End: 50, State: "runnable"},
+			}},
+		},
+		Procs: []procTimeline{
+			{ID: 0, Spans: []procSpan{{Start: 0, End: 20, Goroutine: 1}, {Start: 20, End: 30, Goroutine: 5}}},
+			{ID: 1, Spans: []procSpan{{Start: 35, End: 50, Goroutine: 1}}},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("timeline mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTimelineTruncated(t *testing.T) {
+	b := newTimelineBuilder()
+	states := []trace.GoState{trace.GoRunning, trace.GoRunnable}
+	for i := 0; i <= maxTraceSpans; i++ {
+		b.transition(int64(i), 1, states[i%2], "", "", 0)
+	}
+	got := b.finish()
+	if !got.Truncated {
+		t.Errorf("timeline of %d spans not truncated", maxTraceSpans+1)
+	}
+	if n := len(got.Goroutines[0].Spans); n != maxTraceSpans {
+		t.Errorf("timeline has %d spans; want %d", n, maxTraceSpans)
+	}
+}
+
+func TestSummarizeTraceVersion(t *testing.T) {
+	_, err := summarizeTrace([]byte("go 1.99 trace\x00\x00\x00"))
+	if !errors.Is(err, errTraceVersion) {
+		t.Errorf("summarizeTrace of a go 1.99 trace: got %v, want errTraceVersion", err)
+	}
+}
+
+// TestTrace traces programs built and run with the local go command.
+// It is skipped if the trace format of the local go is too new.
+func TestTrace(t *testing.T) {
+	tests := []struct {
+		name, src string
+		profile   string
+	}{
+		{"Program", `package main
+
+func worker(c chan int) {
+	c <- 1
+}
+
+func main() {
+	c := make(chan int)
+	for i := 0; i < 3; i++ {
+		go worker(c)
+	}
+	for i := 0; i < 3; i++ {
+		<-c
+	}
+}
+`, ""},
+		{"TestAndProfile", `package main
+
+import "testing"
+
+func worker(c chan int) {
+	c <- 1
+}
+
+func TestWorkers(t *testing.T) {
+	c := make(chan int)
+	for i := 0; i < 3; i++ {
+		go worker(c)
+	}
+	for i := 0; i < 3; i++ {
+		<-c
+	}
+}
+`, "heap"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, outDir := runWithOutput(t, tt.src, &buildOptions{profile: tt.profile, trace: true})
+			data, err := os.ReadFile(filepath.Join(outDir, traceFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tl, err := summarizeTrace(data)
+			if errors.Is(err, errTraceVersion) {
+				t.Skip(err)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if tl.Duration <= 0 || len(tl.Procs) == 0 {
+				t.Errorf("timeline has duration %d and %d procs", tl.Duration, len(tl.Procs))
+			}
+			workers := 0
+			for _, g := range tl.Goroutines {
+				if g.Function == "main.worker" {
+					workers++
+				}
+			}
+			if workers != 3 {
+				t.Errorf("timeline has %d worker goroutines; want 3", workers)
+			}
+			if tt.profile != "" {
+				if _, err := os.Stat(filepath.Join(outDir, profileFile)); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
@@ -63,6 +63,34 @@ func (fs *fileSet) Format() []byte {
 	return txtar.Format(a)
 }
 
+// Limits on the files of a txtar archive. Vendored dependencies, the
+// files in the vendor directory, are limited separately, since they
+// are typically much larger than the user's own files. They are set in
+// main from the configuration.
+var (
+	limitNumFiles       = 20      // arbitrary
+	limitNumVendorFiles = 500     // arbitrary
+	maxVendorSize       = 1 << 20 // bytes; the user's files are limited by maxSnippetSize when shared
+)
+
+// isVendored reports whether the named file in a txtar archive is
+// part of a vendor directory at the root of the archive.
+func isVendored(filename string) bool {
+	return strings.HasPrefix(filename, "vendor/")
+}
+
+// snippetSize returns the size of the txtar archive src, not counting
+// the files in its vendor directory.
+func snippetSize(src []byte) int {
+	size := len(src)
+	for _, f := range txtar.Parse(src).Files {
+		if isVendored(f.Name) {
+			size -= len("-- "+f.Name+" --\n") + len(f.Data)
+		}
+	}
+	return size
+}
+
 // splitFiles splits the user's input program src into 1 or more
 // files, splitting it based on boundaries as specified by the "txtar"
 // format. It returns an error if any filenames are bogus or
@@ -84,11 +112,24 @@ func splitFiles(src []byte) (*fileSet, error) {
 		fs.noHeader = true
 		fs.AddFile(progName, a.Comment)
 	}
-	const limitNumFiles = 20 // arbitrary
-	numFiles := len(a.Files) + fs.Num()
+	numFiles, numVendorFiles, vendorSize := fs.Num(), 0, 0
+	for _, f := range a.Files {
+		if isVendored(f.Name) {
+			numVendorFiles++
+			vendorSize += len(f.Data)
+		} else {
+			numFiles++
+		}
+	}
 	if numFiles > limitNumFiles {
 		return nil, fmt.Errorf("too many files in txtar archive (%v exceeds limit of %v)", numFiles, limitNumFiles)
 	}
+	if numVendorFiles > limitNumVendorFiles {
+		return nil, fmt.Errorf("too many vendored files in txtar archive (%v exceeds limit of %v)", numVendorFiles, limitNumVendorFiles)
+	}
+	if vendorSize > maxVendorSize {
+		return nil, fmt.Errorf("vendored files too large (%v bytes exceeds limit of %v)", vendorSize, maxVendorSize)
+	}
 	for _, f := range a.Files {
 		if len(f.Name) > 200 { // arbitrary limit
 			return nil, errors.New("file name too long")
@@ -100,7 +141,12 @@ func splitFiles(src []byte) (*fileSet, error) {
 			return nil, fmt.Errorf("invalid file name %q", f.Name)
 		}
 		parts := strings.Split(f.Name, "/")
-		if len(parts) > 10 { // arbitrary limit
+		maxDepth := 10 // arbitrary limit
+		if isVendored(f.Name) {
+			// Import paths alone are often several elements deep.
+			maxDepth = 20
+		}
+		if len(parts) > maxDepth {
 			return nil, fmt.Errorf("file name %q too deep", f.Name)
 		}
 		for _, part := range parts {
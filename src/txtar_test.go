@@ -117,6 +117,21 @@ func TestSplitFiles(t *testing.T) {
 			in:      strings.Repeat("-- x.go --\n", 50),
 			wantErr: `too many files in txtar archive (50 exceeds limit of 20)`,
 		},
+		{
+			name: "vendored files",
+			in:   vendoredIn,
+			want: vendoredWant,
+		},
+		{
+			name:    "reject many vendored files",
+			in:      strings.Repeat("-- vendor/x.go --\n", 501),
+			wantErr: `too many vendored files in txtar archive (501 exceeds limit of 500)`,
+		},
+		{
+			name:    "reject large vendored files",
+			in:      "-- vendor/x.go --\n" + strings.Repeat("x", 1<<20) + "\n",
+			wantErr: `vendored files too large (1048577 bytes exceeds limit of 1048576)`,
+		},
 	} {
 		got, err := splitFiles([]byte(tt.in))
 		var gotErr string
@@ -140,6 +155,19 @@ func TestSplitFiles(t *testing.T) {
 	}
 }
 
+// vendoredIn is an archive with more vendored files than limitNumFiles,
+// some deeper than the limit for other files, and vendoredWant its files.
+var vendoredIn, vendoredWant = func() (string, *fileSet) {
+	in := "-- main.go --\npackage main\n"
+	want := newFileSet("main.go", "package main\n")
+	for i := 0; i < 2*limitNumFiles; i++ {
+		name := fmt.Sprintf("vendor/example.com/a/b/c/d/e/f/g/h/i/f%d.go", i)
+		in += "-- " + name + " --\npackage i\n"
+		want.AddFile(name, []byte("package i\n"))
+	}
+	return in, want
+}()
+
 func filesAsString(fs *fileSet) string {
 	var sb strings.Builder
 	for i, f := range fs.files {
@@ -151,3 +179,13 @@ func filesAsString(fs *fileSet) string {
 	}
 	return sb.String()
 }
+
+func TestSnippetSize(t *testing.T) {
+	const user = "package main\n-- go.mod --\nmodule play\n"
+	if got, want := snippetSize([]byte(user)), len(user); got != want {
+		t.Errorf("snippetSize without vendored files = %d; want %d", got, want)
+	}
+	if got, want := snippetSize([]byte(user+"-- vendor/modules.txt --\n# example.com/a v1.0.0\n")), len(user); got != want {
+		t.Errorf("snippetSize with vendored files = %d; want %d", got, want)
+	}
+}
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io/ioutil"
@@ -16,6 +17,7 @@ import (
 
 	"go.opencensus.io/stats"
 	"go.opencensus.io/tag"
+	"golang.org/x/playground/internal"
 )
 
 // vetCheck runs the "vet" tool on the source code in req.Body.
@@ -37,7 +39,7 @@ func vetCheck(ctx context.Context, req *request) (*response, error) {
 	if err := ioutil.WriteFile(in, []byte(req.Body), 0400); err != nil {
 		return nil, fmt.Errorf("error creating temp file %q: %v", in, err)
 	}
-	vetOutput, err := vetCheckInDir(ctx, tmpDir, os.Getenv("GOPATH"))
+	_, vetOutput, err := vetCheckInDir(ctx, tmpDir, ".", os.Getenv("GOPATH"))
 	if err != nil {
 		// This is about errors running vet, not vet returning output.
 		return nil, err
@@ -45,12 +47,14 @@ func vetCheck(ctx context.Context, req *request) (*response, error) {
 	return &response{Errors: vetOutput}, nil
 }
 
-// vetCheckInDir runs go vet in the provided directory, using the
-// provided GOPATH value. The returned error is only about whether
+// vetCheckInDir runs go vet on pkg in the provided directory, using
+// the provided GOPATH value. The returned error is only about whether
 // go vet was able to run, not whether vet reported problem. The
-// returned value is ("", nil) if vet successfully found nothing,
-// and (non-empty, nil) if vet ran and found issues.
-func vetCheckInDir(ctx context.Context, dir, goPath string) (output string, execErr error) {
+// returned output is empty if vet successfully found nothing. If vet
+// found issues, they are listed in output and returned as diagnostics,
+// with their suggested fixes. If vet could not check the package, for
+// example because it does not compile, output is vet's error output.
+func vetCheckInDir(ctx context.Context, dir, pkg, goPath string) (diags []diagnostic, output string, execErr error) {
 	start := time.Now()
 	defer func() {
 		status := "success"
@@ -63,22 +67,28 @@ func vetCheckInDir(ctx context.Context, dir, goPath string) (output string, exec
 			mGoVetLatency.M(float64(time.Since(start))/float64(time.Millisecond)))
 	}()
 
-	cmd := exec.Command("go", "vet", "--tags=faketime", "--mod=mod")
-	cmd.Dir = dir
-	// Linux go binary is not built with CGO_ENABLED=0.
-	// Prevent vet to compile packages in cgo mode.
-	// See #26307.
-	cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOPATH="+goPath)
-	cmd.Env = append(cmd.Env,
-		"GO111MODULE=on",
-		"GOPROXY="+playgroundGoproxy(),
-	)
-	out, err := cmd.CombinedOutput()
+	release, err := acquireBuild(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer release()
+	cmd := vetCommand(dir, pkg, goPath, "-json")
+	var buf bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &buf, &buf
+	if err := cmd.Start(); err != nil {
+		return nil, "", fmt.Errorf("error starting go vet: %v", err)
+	}
+	err = internal.WaitOrStop(ctx, cmd, os.Interrupt, 250*time.Millisecond)
 	if err == nil {
-		return "", nil
+		diags, err := parseDiagnostics(buf.Bytes(), dir)
+		if err != nil {
+			return nil, "", err
+		}
+		return diags, formatDiagnostics(diags), nil
 	}
+	out := buf.Bytes()
 	if _, ok := err.(*exec.ExitError); !ok {
-		return "", fmt.Errorf("error vetting go source: %v", err)
+		return nil, "", fmt.Errorf("error vetting go source: %v", err)
 	}
 
 	// Rewrite compiler errors to refer to progName
@@ -91,5 +101,66 @@ func vetCheckInDir(ctx context.Context, dir, goPath string) (output string, exec
 			errs = errs[nl+1:]
 		}
 	}
-	return errs, nil
+	return nil, errs, nil
+}
+
+// formatDiagnostics formats diags as go vet prints them without -json.
+func formatDiagnostics(diags []diagnostic) string {
+	var b strings.Builder
+	for _, d := range diags {
+		file := d.File
+		if !filepath.IsAbs(file) {
+			file = "./" + file
+		}
+		switch {
+		case d.Line != 0 && d.Column != 0:
+			fmt.Fprintf(&b, "%s:%d:%d: %s\n", file, d.Line, d.Column, d.Message)
+		case d.Line != 0:
+			fmt.Fprintf(&b, "%s:%d: %s\n", file, d.Line, d.Message)
+		default:
+			fmt.Fprintf(&b, "%s: %s\n", file, d.Message)
+		}
+	}
+	return b.String()
+}
+
+// vetCommand returns the go vet command, with the additional flags in
+// args, that vets pkg in dir using the provided GOPATH value.
+func vetCommand(dir, pkg, goPath string, args ...string) *exec.Cmd {
+	cmd := exec.Command("go", "vet", "--tags=faketime")
+	if mod := modFlag(dir); mod != "" {
+		cmd.Args = append(cmd.Args, mod)
+	}
+	cmd.Args = append(cmd.Args, args...)
+	if pkg != progName {
+		cmd.Args = append(cmd.Args, pkg)
+	}
+	cmd.Dir = dir
+	cmd.Env = toolEnv(goPath)
+	return cmd
+}
+
+// toolEnv returns the environment of the go command, and of tools
+// running it, that check a program after it is built using the
+// provided GOPATH value.
+func toolEnv(goPath string) []string {
+	// Linux go binary is not built with CGO_ENABLED=0.
+	// Prevent vet to compile packages in cgo mode.
+	// See #26307.
+	env := append(os.Environ(), "CGO_ENABLED=0", "GOPATH="+goPath)
+	env = append(env,
+		"GO111MODULE=on",
+		"GOPROXY="+playgroundGoproxy(),
+	)
+	if sumdb := playgroundGosumdb(); sumdb != "" {
+		env = append(env, "GOSUMDB="+sumdb)
+	}
+	if nosumdb := playgroundGonosumdb(); nosumdb != "" {
+		env = append(env, "GONOSUMDB="+nosumdb)
+	}
+	if caches != nil {
+		// The caller is using the caches.
+		env = append(env, "GOCACHE="+caches.goCache, "GOMODCACHE="+caches.modCache)
+	}
+	return env
 }
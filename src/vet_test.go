@@ -0,0 +1,94 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestVetCheckInDir(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	vet := func(prog string) ([]diagnostic, string) {
+		t.Helper()
+		dir := t.TempDir()
+		for name, data := range map[string]string{"go.mod": "module play\n", "prog.go": prog} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		diags, out, err := vetCheckInDir(context.Background(), dir, progName, os.Getenv("GOPATH"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return diags, out
+	}
+
+	const prog = "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tx := 65\n\tfmt.Println(string(x))\n}\n"
+	diags, out := vet(prog)
+	const msg = "conversion from int to string yields a string of one rune, not a string of digits"
+	if want := "./prog.go:7:14: " + msg + "\n"; out != want {
+		t.Errorf("vet output = %q; want %q", out, want)
+	}
+	if len(diags) != 1 || len(diags[0].SuggestedFixes) == 0 {
+		t.Fatalf("vet diagnostics = %+v; want one with suggested fixes", diags)
+	}
+	want := []textEdit{{File: "prog.go", Start: 64, End: 70, New: "fmt.Sprint"}}
+	if diff := cmp.Diff(want, diags[0].SuggestedFixes[0].Edits); diff != "" {
+		t.Errorf("suggested fix mismatch (-want +got):\n%s", diff)
+	}
+
+	diags, out = vet("package main\n\nfunc main() { x := 1 }\n")
+	if diags != nil || !strings.Contains(out, "declared and not used") {
+		t.Errorf("vet of a bad program = %+v, %q; want its errors", diags, out)
+	}
+
+	if diags, out = vet("package main\n\nfunc main() {}\n"); diags != nil || out != "" {
+		t.Errorf("vet of a good program = %+v, %q; want nothing", diags, out)
+	}
+}
+
+// TestVetProfiled vets a program rewritten by addProfiling, whose
+// suggested fixes do not apply to the user's source.
+func TestVetProfiled(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	const prog = "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tx := 65\n\tfmt.Println(string(x))\n}\n"
+	for _, opt := range []*buildOptions{{profile: "cpu"}, {trace: true}} {
+		files, err := splitFiles([]byte(prog))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files.AddFile("go.mod", []byte("module play\n"))
+		addProfiling(files, progName, opt, false)
+		dir := t.TempDir()
+		for name, data := range files.m {
+			if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		diags, _, err := vetCheckInDir(context.Background(), dir, progName, os.Getenv("GOPATH"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(diags) != 1 || len(diags[0].SuggestedFixes) == 0 {
+			t.Fatalf("vet diagnostics = %+v; want one with suggested fixes", diags)
+		}
+		b := &buildResult{profile: opt.profile, trace: opt.trace}
+		diags = b.userFixes(diags)
+		if diags[0].Line != 7 || diags[0].SuggestedFixes != nil {
+			t.Errorf("userFixes with %+v = %+v; want a diagnostic on line 7 without fixes", *opt, diags[0])
+		}
+	}
+}
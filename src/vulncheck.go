@@ -0,0 +1,248 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"golang.org/x/playground/internal"
+)
+
+// vulnDB is the directory of the local mirror of the Go vulnerability
+// database, in the layout served at https://vuln.go.dev. It is set in
+// main according to the vuln_db setting; if empty, the /vulncheck
+// endpoint is not served.
+var vulnDB string
+
+// vulnFinding is a known vulnerability of a module used by a snippet,
+// as reported by govulncheck.
+type vulnFinding struct {
+	// ID is the ID of the vulnerability in the database, such as
+	// GO-2022-0969.
+	ID      string `json:"id"`
+	Summary string `json:"summary,omitempty"`
+	// Module and Version are the vulnerable module and the version
+	// in use; the standard library is the module "stdlib".
+	Module       string `json:"module"`
+	Version      string `json:"version,omitempty"`
+	FixedVersion string `json:"fixedVersion,omitempty"`
+	// Package is the vulnerable package, if the snippet imports it.
+	Package string `json:"package,omitempty"`
+	// Symbol is the vulnerable function or method, such as
+	// "Client.Do", if the snippet calls it, and File and Line are
+	// the position in the snippet of the call that reaches it.
+	Symbol string `json:"symbol,omitempty"`
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+}
+
+// compileVulncheck builds the user program in req.Body and checks the
+// modules it uses, and the functions it calls, against vulnDB, with
+// govulncheck. The findings are returned in *response.Vulns. The
+// program is not run, and no network access is needed since the build
+// has already downloaded the modules.
+func compileVulncheck(ctx context.Context, req *request) (*response, error) {
+	tmpDir, err := ioutil.TempDir("", "sandbox")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	br, err := sandboxBuild(ctx, tmpDir, []byte(req.Body), &buildOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if br.errorMessage != "" {
+		return &response{Errors: br.errorMessage, CompileErrors: br.compileErrors}, nil
+	}
+	defer br.cleanup()
+
+	ctx, cancel := context.WithTimeout(ctx, maxBuildTime)
+	defer cancel()
+	vulns, err := vulncheckInDir(ctx, tmpDir, br.pkg, br.goPath)
+	if err != nil {
+		log.Printf("running govulncheck: %v", err)
+		return nil, fmt.Errorf("running govulncheck: %w", err)
+	}
+	return &response{
+		IsTest: br.testParam != "",
+		Vulns:  vulns,
+	}, nil
+}
+
+// vulncheckInDir runs govulncheck on pkg in dir, built using the
+// provided GOPATH value, against vulnDB.
+func vulncheckInDir(ctx context.Context, dir, pkg, goPath string) ([]vulnFinding, error) {
+	db, err := filepath.Abs(vulnDB)
+	if err != nil {
+		return nil, err
+	}
+	if pkg == progName {
+		pkg = "."
+	}
+	release, err := acquireBuild(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	cmd := exec.Command("govulncheck", "-db", "file://"+filepath.ToSlash(db), "-json", "-tags", "faketime", pkg)
+	cmd.Dir = dir
+	// The modules are already in the module cache.
+	cmd.Env = append(toolEnv(goPath), "GOPROXY=off", "GOFLAGS="+modFlag(dir))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting govulncheck: %v", err)
+	}
+	if err := internal.WaitOrStop(ctx, cmd, os.Interrupt, 250*time.Millisecond); err != nil {
+		return nil, fmt.Errorf("%v: %s", err, bytes.Replace(stderr.Bytes(), []byte(dir), nil, -1))
+	}
+	return parseVulncheck(stdout.Bytes(), dir)
+}
+
+// parseVulncheck parses the JSON output of govulncheck run in dir, a
+// stream of messages. For each vulnerability, govulncheck reports a
+// finding for the vulnerable module, then one for each vulnerable
+// package that is imported, then one for each vulnerable symbol that
+// is called. Only the most precise findings of each vulnerability are
+// returned.
+func parseVulncheck(out []byte, dir string) ([]vulnFinding, error) {
+	type frame struct {
+		Module   string `json:"module"`
+		Version  string `json:"version"`
+		Package  string `json:"package"`
+		Function string `json:"function"`
+		Receiver string `json:"receiver"`
+		Position *struct {
+			Filename string `json:"filename"`
+			Line     int    `json:"line"`
+		} `json:"position"`
+	}
+	var (
+		summaries = map[string]string{}
+		byID      = map[string][]vulnFinding{}
+		levels    = map[string]int{} // 1 for modules, 2 for packages, 3 for symbols
+	)
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var msg struct {
+			OSV *struct {
+				ID      string `json:"id"`
+				Summary string `json:"summary"`
+			} `json:"osv"`
+			Finding *struct {
+				OSV          string  `json:"osv"`
+				FixedVersion string  `json:"fixed_version"`
+				Trace        []frame `json:"trace"`
+			} `json:"finding"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error decoding govulncheck output: %v", err)
+		}
+		if msg.OSV != nil {
+			summaries[msg.OSV.ID] = msg.OSV.Summary
+		}
+		f := msg.Finding
+		if f == nil || len(f.Trace) == 0 {
+			continue
+		}
+		// The first frame is the vulnerable symbol, and the last the
+		// snippet's code reaching it.
+		vuln := f.Trace[0]
+		v := vulnFinding{
+			ID:           f.OSV,
+			Module:       vuln.Module,
+			Version:      vuln.Version,
+			FixedVersion: f.FixedVersion,
+			Package:      vuln.Package,
+		}
+		level := 1
+		if v.Package != "" {
+			level = 2
+		}
+		if vuln.Function != "" {
+			level = 3
+			v.Symbol = vuln.Function
+			if vuln.Receiver != "" {
+				v.Symbol = vuln.Receiver + "." + vuln.Function
+			}
+			if p := f.Trace[len(f.Trace)-1].Position; p != nil && len(f.Trace) > 1 {
+				if file := relativeFile(p.Filename, dir); !filepath.IsAbs(file) {
+					v.File, v.Line = file, p.Line
+				}
+			}
+		}
+		switch {
+		case level > levels[v.ID]:
+			levels[v.ID] = level
+			byID[v.ID] = []vulnFinding{v}
+		case level == levels[v.ID]:
+			byID[v.ID] = append(byID[v.ID], v)
+		}
+	}
+	var vulns []vulnFinding
+	for id, vs := range byID {
+		for _, v := range vs {
+			v.Summary = summaries[id]
+			vulns = append(vulns, v)
+		}
+	}
+	sort.Slice(vulns, func(i, j int) bool {
+		a, b := vulns[i], vulns[j]
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		if a.Symbol != b.Symbol {
+			return a.Symbol < b.Symbol
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return vulns, nil
+}
+
+// vulnDBModified returns the modification time recorded in the index
+// of vulnDB, which identifies its contents.
+func vulnDBModified() (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(vulnDB, "index", "db.json"))
+	if err != nil {
+		return "", err
+	}
+	var index struct {
+		Modified string `json:"modified"`
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return "", fmt.Errorf("%s: %v", filepath.Join(vulnDB, "index", "db.json"), err)
+	}
+	return index.Modified, nil
+}
+
+// handleVulncheck serves /vulncheck. Results are cached by the
+// modification time of vulnDB, so that updating the mirror invalidates
+// them.
+func (s *server) handleVulncheck(w http.ResponseWriter, r *http.Request) {
+	modified, err := vulnDBModified()
+	if err != nil {
+		s.log.Errorf("reading vulnerability database: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	s.commandHandler("vulncheck-"+modified, compileVulncheck)(w, r)
+}
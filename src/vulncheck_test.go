@@ -0,0 +1,78 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseVulncheck(t *testing.T) {
+	const out = `{"config": {"protocol_version": "v1.0.0", "scanner_name": "govulncheck", "db": "file:///vulndb", "scan_level": "symbol"}}
+{"progress": {"message": "Scanning your code and 3 packages across 1 dependent module for known vulnerabilities..."}}
+{"osv": {"id": "GO-2021-0113", "summary": "Out-of-bounds read in golang.org/x/text/language"}}
+{"osv": {"id": "GO-2022-1059", "summary": "Denial of service via crafted Accept-Language header in golang.org/x/text/language"}}
+{"osv": {"id": "GO-2020-0015", "summary": "Infinite loop when decoding some inputs in golang.org/x/text"}}
+{"finding": {"osv": "GO-2021-0113", "fixed_version": "v0.3.7", "trace": [{"module": "golang.org/x/text", "version": "v0.3.5"}]}}
+{"finding": {"osv": "GO-2022-1059", "fixed_version": "v0.3.8", "trace": [{"module": "golang.org/x/text", "version": "v0.3.5"}]}}
+{"finding": {"osv": "GO-2020-0015", "fixed_version": "v0.3.3", "trace": [{"module": "golang.org/x/text", "version": "v0.3.5"}]}}
+{"finding": {"osv": "GO-2021-0113", "fixed_version": "v0.3.7", "trace": [{"module": "golang.org/x/text", "version": "v0.3.5", "package": "golang.org/x/text/language"}]}}
+{"finding": {"osv": "GO-2022-1059", "fixed_version": "v0.3.8", "trace": [{"module": "golang.org/x/text", "version": "v0.3.5", "package": "golang.org/x/text/language"}]}}
+{"finding": {"osv": "GO-2021-0113", "fixed_version": "v0.3.7", "trace": [
+	{"module": "golang.org/x/text", "version": "v0.3.5", "package": "golang.org/x/text/language", "function": "Parse", "position": {"filename": "/gopath/pkg/mod/golang.org/x/text@v0.3.5/language/parse.go", "line": 33}},
+	{"module": "play", "package": "play", "function": "main", "position": {"filename": "/tmp/sandbox1/prog.go", "line": 11, "column": 23}}
+]}}
+{"finding": {"osv": "GO-2021-0113", "fixed_version": "v0.3.7", "trace": [
+	{"module": "golang.org/x/text", "version": "v0.3.5", "package": "golang.org/x/text/language", "function": "MatchStrings", "position": {"filename": "/gopath/pkg/mod/golang.org/x/text@v0.3.5/language/language.go", "line": 12}},
+	{"module": "play", "package": "play/sub", "function": "Match", "position": {"filename": "/tmp/sandbox1/sub/sub.go", "line": 7, "column": 9}},
+	{"module": "play", "package": "play", "function": "main", "position": {"filename": "/tmp/sandbox1/prog.go", "line": 12, "column": 2}}
+]}}
+{"finding": {"osv": "GO-2022-1059", "fixed_version": "v0.3.8", "trace": [
+	{"module": "golang.org/x/text", "version": "v0.3.5", "package": "golang.org/x/text/language", "function": "Tag", "receiver": "*Matcher"}
+]}}
+`
+	const (
+		sum0113 = "Out-of-bounds read in golang.org/x/text/language"
+		sum1059 = "Denial of service via crafted Accept-Language header in golang.org/x/text/language"
+	)
+	want := []vulnFinding{
+		{ID: "GO-2020-0015", Summary: "Infinite loop when decoding some inputs in golang.org/x/text", Module: "golang.org/x/text", Version: "v0.3.5", FixedVersion: "v0.3.3"},
+		{ID: "GO-2021-0113", Summary: sum0113, Module: "golang.org/x/text", Version: "v0.3.5", FixedVersion: "v0.3.7", Package: "golang.org/x/text/language", Symbol: "MatchStrings", File: "prog.go", Line: 12},
+		{ID: "GO-2021-0113", Summary: sum0113, Module: "golang.org/x/text", Version: "v0.3.5", FixedVersion: "v0.3.7", Package: "golang.org/x/text/language", Symbol: "Parse", File: "prog.go", Line: 11},
+		{ID: "GO-2022-1059", Summary: sum1059, Module: "golang.org/x/text", Version: "v0.3.5", FixedVersion: "v0.3.8", Package: "golang.org/x/text/language", Symbol: "*Matcher.Tag"},
+	}
+	got, err := parseVulncheck([]byte(out), "/tmp/sandbox1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseVulncheck mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := parseVulncheck([]byte("not json"), "/tmp/sandbox1"); err == nil {
+		t.Errorf("parseVulncheck of bad output succeeded")
+	}
+}
+
+func TestVulnDBModified(t *testing.T) {
+	defer func(db string) { vulnDB = db }(vulnDB)
+	vulnDB = t.TempDir()
+	if _, err := vulnDBModified(); err == nil {
+		t.Errorf("vulnDBModified of an empty directory succeeded")
+	}
+	if err := os.MkdirAll(filepath.Join(vulnDB, "index"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	const index = `{"modified":"2026-10-01T00:00:00Z"}`
+	if err := os.WriteFile(filepath.Join(vulnDB, "index", "db.json"), []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := vulnDBModified(); err != nil || got != "2026-10-01T00:00:00Z" {
+		t.Errorf("vulnDBModified() = %q, %v; want %q", got, err, "2026-10-01T00:00:00Z")
+	}
+}